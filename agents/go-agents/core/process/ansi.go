@@ -0,0 +1,281 @@
+//
+// Copyright (c) 2012-2017 Codenvy, S.A.
+// All rights reserved. This program and the accompanying materials
+// are made available under the terms of the Eclipse Public License v1.0
+// which accompanies this distribution, and is available at
+// http://www.eclipse.org/legal/epl-v10.html
+//
+// Contributors:
+//   Codenvy, S.A. - initial API and implementation
+//
+
+package process
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+)
+
+const escape = 0x1b
+
+// the colors of SGR codes 30-37 and 90-97, the same colors are used for backgrounds
+var ansiColors = [16]string{
+	"#000000", "#cd0000", "#00cd00", "#cdcd00", "#0000ee", "#cd00cd", "#00cdcd", "#e5e5e5",
+	"#7f7f7f", "#ff0000", "#00ff00", "#ffff00", "#5c5cff", "#ff00ff", "#00ffff", "#ffffff",
+}
+
+// StripAnsi removes ANSI/VT100 escape sequences from the text.
+func StripAnsi(text string) string {
+	if strings.IndexByte(text, escape) == -1 {
+		return text
+	}
+	var buffer bytes.Buffer
+	for i := 0; i < len(text); {
+		if text[i] != escape {
+			buffer.WriteByte(text[i])
+			i++
+			continue
+		}
+		size, _, _ := parseEscape(text[i:])
+		i += size
+	}
+	return buffer.String()
+}
+
+// AnsiHTMLConverter converts SGR color codes into html spans with inline styles,
+// other escape sequences are dropped and the text is html escaped.
+// The converter keeps the style between converted texts, as the terminal does between lines,
+// so the same converter should be used for all the logs of the process.
+type AnsiHTMLConverter struct {
+	style ansiStyle
+}
+
+type ansiStyle struct {
+	fg, bg                               string
+	bold, faint, italic, underline, swap bool
+}
+
+// NewAnsiHTMLConverter creates a converter with the default style.
+func NewAnsiHTMLConverter() *AnsiHTMLConverter {
+	return &AnsiHTMLConverter{}
+}
+
+// Convert returns the html of the text, all the spans opened in the html are closed.
+func (c *AnsiHTMLConverter) Convert(text string) string {
+	var buffer bytes.Buffer
+	open := c.openSpan(&buffer, false)
+	start := 0
+	for i := 0; i < len(text); {
+		if text[i] != escape {
+			i++
+			continue
+		}
+		buffer.WriteString(html.EscapeString(text[start:i]))
+		size, sgr, params := parseEscape(text[i:])
+		i += size
+		start = i
+		if sgr {
+			c.style.apply(params)
+			open = c.openSpan(&buffer, open)
+		}
+	}
+	buffer.WriteString(html.EscapeString(text[start:]))
+	if open {
+		buffer.WriteString("</span>")
+	}
+	return buffer.String()
+}
+
+// Closes the opened span and opens a new one if the current style is not the default one.
+func (c *AnsiHTMLConverter) openSpan(buffer *bytes.Buffer, opened bool) bool {
+	if opened {
+		buffer.WriteString("</span>")
+	}
+	css := c.style.css()
+	if css == "" {
+		return false
+	}
+	buffer.WriteString(`<span style="` + css + `">`)
+	return true
+}
+
+func (style *ansiStyle) css() string {
+	fg, bg := style.fg, style.bg
+	if style.swap {
+		fg, bg = bg, fg
+		if fg == "" {
+			fg = ansiColors[0]
+		}
+		if bg == "" {
+			bg = ansiColors[7]
+		}
+	}
+	var rules []string
+	if fg != "" {
+		rules = append(rules, "color:"+fg)
+	}
+	if bg != "" {
+		rules = append(rules, "background-color:"+bg)
+	}
+	if style.bold {
+		rules = append(rules, "font-weight:bold")
+	}
+	if style.faint {
+		rules = append(rules, "opacity:0.5")
+	}
+	if style.italic {
+		rules = append(rules, "font-style:italic")
+	}
+	if style.underline {
+		rules = append(rules, "text-decoration:underline")
+	}
+	return strings.Join(rules, ";")
+}
+
+// Applies SGR parameters to the style, unsupported parameters are ignored.
+func (style *ansiStyle) apply(params []int) {
+	if len(params) == 0 {
+		params = []int{0}
+	}
+	for i := 0; i < len(params); i++ {
+		switch p := params[i]; {
+		case p == 0:
+			*style = ansiStyle{}
+		case p == 1:
+			style.bold = true
+		case p == 2:
+			style.faint = true
+		case p == 3:
+			style.italic = true
+		case p == 4:
+			style.underline = true
+		case p == 7:
+			style.swap = true
+		case p == 22:
+			style.bold, style.faint = false, false
+		case p == 23:
+			style.italic = false
+		case p == 24:
+			style.underline = false
+		case p == 27:
+			style.swap = false
+		case p >= 30 && p <= 37:
+			style.fg = ansiColors[p-30]
+		case p >= 90 && p <= 97:
+			style.fg = ansiColors[p-90+8]
+		case p == 39:
+			style.fg = ""
+		case p >= 40 && p <= 47:
+			style.bg = ansiColors[p-40]
+		case p >= 100 && p <= 107:
+			style.bg = ansiColors[p-100+8]
+		case p == 49:
+			style.bg = ""
+		case p == 38 || p == 48:
+			color, used := extendedColor(params[i+1:])
+			i += used
+			if p == 38 {
+				style.fg = color
+			} else {
+				style.bg = color
+			}
+		}
+	}
+}
+
+// Parses the color of 38 and 48 codes, either '5;n' or '2;r;g;b'.
+// Returns the color and the number of used parameters.
+func extendedColor(params []int) (string, int) {
+	if len(params) >= 2 && params[0] == 5 {
+		return color256(params[1]), 2
+	}
+	if len(params) >= 4 && params[0] == 2 {
+		return fmt.Sprintf("#%02x%02x%02x", params[1]&0xff, params[2]&0xff, params[3]&0xff), 4
+	}
+	return "", len(params)
+}
+
+// Returns the color of the xterm 256 colors palette.
+func color256(n int) string {
+	switch {
+	case n < 0 || n > 255:
+		return ""
+	case n < 16:
+		return ansiColors[n]
+	case n < 232:
+		n -= 16
+		levels := [6]int{0, 95, 135, 175, 215, 255}
+		return fmt.Sprintf("#%02x%02x%02x", levels[n/36], levels[n/6%6], levels[n%6])
+	default:
+		gray := 8 + (n-232)*10
+		return fmt.Sprintf("#%02x%02x%02x", gray, gray, gray)
+	}
+}
+
+// Parses the escape sequence the text starts with.
+// Returns the size of the sequence, whether it's SGR sequence and its parameters.
+// Incomplete sequence takes the rest of the text.
+func parseEscape(text string) (int, bool, []int) {
+	if len(text) < 2 {
+		return len(text), false, nil
+	}
+	switch text[1] {
+	case '[':
+		// CSI: parameter bytes, intermediate bytes and the final byte
+		i := 2
+		for i < len(text) && text[i] >= 0x30 && text[i] <= 0x3f {
+			i++
+		}
+		paramsEnd := i
+		for i < len(text) && text[i] >= 0x20 && text[i] <= 0x2f {
+			i++
+		}
+		if i == len(text) {
+			return len(text), false, nil
+		}
+		if text[i] < 0x40 || text[i] > 0x7e {
+			// malformed sequence, only the introducer is dropped
+			return 2, false, nil
+		}
+		if text[i] != 'm' || paramsEnd != i {
+			return i + 1, false, nil
+		}
+		return i + 1, true, sgrParams(text[2:paramsEnd])
+	case ']', 'P', '_', '^':
+		// OSC and other strings terminated by BEL or ST
+		for i := 2; i < len(text); i++ {
+			if text[i] == 0x07 {
+				return i + 1, false, nil
+			}
+			if text[i] == escape && i+1 < len(text) && text[i+1] == '\\' {
+				return i + 2, false, nil
+			}
+		}
+		return len(text), false, nil
+	case '(', ')', '*', '+', '#', '%':
+		// character set designation
+		if len(text) < 3 {
+			return len(text), false, nil
+		}
+		return 3, false, nil
+	default:
+		return 2, false, nil
+	}
+}
+
+func sgrParams(text string) []int {
+	if text == "" {
+		return nil
+	}
+	// empty parameters mean 0, colon is the separator of the extended colors parameters
+	parts := strings.Split(strings.Replace(text, ":", ";", -1), ";")
+	params := make([]int, len(parts))
+	for i, part := range parts {
+		// invalid parameters are treated as 0 as terminals do
+		params[i], _ = strconv.Atoi(part)
+	}
+	return params
+}
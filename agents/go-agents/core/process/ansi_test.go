@@ -0,0 +1,57 @@
+//
+// Copyright (c) 2012-2017 Codenvy, S.A.
+// All rights reserved. This program and the accompanying materials
+// are made available under the terms of the Eclipse Public License v1.0
+// which accompanies this distribution, and is available at
+// http://www.eclipse.org/legal/epl-v10.html
+//
+// Contributors:
+//   Codenvy, S.A. - initial API and implementation
+//
+
+package process
+
+import "testing"
+
+func TestStripAnsi(t *testing.T) {
+	cases := map[string]string{
+		"plain text":                               "plain text",
+		"\x1b[1;31mred\x1b[0m text":                "red text",
+		"\x1b]0;title\x07prompt$ ":                 "prompt$ ",
+		"\x1b]8;;http://a\x1b\\link\x1b]8;;\x1b\\": "link",
+		"\x1b(Bcharset\x1b[2K\x1b[?25l":            "charset",
+		"incomplete\x1b[1":                         "incomplete",
+	}
+	for text, expected := range cases {
+		if stripped := StripAnsi(text); stripped != expected {
+			t.Errorf("Expected %q to be stripped to %q but got %q", text, expected, stripped)
+		}
+	}
+}
+
+func TestAnsiHTMLConverterConvertsColors(t *testing.T) {
+	converter := NewAnsiHTMLConverter()
+	html := converter.Convert("<b>\x1b[1;31merror\x1b[0m & \x1b[38;5;21mblue\x1b[39m")
+
+	expected := `&lt;b&gt;<span style="color:#cd0000;font-weight:bold">error</span> &amp; <span style="color:#0000ff">blue</span>`
+	if html != expected {
+		t.Fatalf("Expected html\n%s\nbut got\n%s", expected, html)
+	}
+}
+
+func TestAnsiHTMLConverterKeepsStyleBetweenTexts(t *testing.T) {
+	converter := NewAnsiHTMLConverter()
+	first := converter.Convert("\x1b[42mgreen")
+	second := converter.Convert("still green\x1b[m")
+	third := converter.Convert("default")
+
+	if first != `<span style="background-color:#00cd00">green</span>` {
+		t.Fatalf("Unexpected first html %s", first)
+	}
+	if second != `<span style="background-color:#00cd00">still green</span>` {
+		t.Fatalf("Expected the style to be kept but got %s", second)
+	}
+	if third != "default" {
+		t.Fatalf("Expected the style to be reset but got %s", third)
+	}
+}
@@ -0,0 +1,321 @@
+//
+// Copyright (c) 2012-2017 Codenvy, S.A.
+// All rights reserved. This program and the accompanying materials
+// are made available under the terms of the Eclipse Public License v1.0
+// which accompanies this distribution, and is available at
+// http://www.eclipse.org/legal/epl-v10.html
+//
+// Contributors:
+//   Codenvy, S.A. - initial API and implementation
+//
+
+package process
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Conditions of a chain step.
+const (
+	// RunOnSuccess means that step runs if none of the previous steps failed.
+	RunOnSuccess = "on-success"
+	// RunOnFailure means that step runs if any of the previous steps failed.
+	RunOnFailure = "on-failure"
+	// RunAlways means that step runs regardless of the previous steps.
+	RunAlways = "always"
+)
+
+// Statuses of a chain and its steps.
+const (
+	// PendingStatus means that step waits for the previous steps.
+	PendingStatus = "pending"
+	// RunningStatus means that chain or step is running.
+	RunningStatus = "running"
+	// SucceededStatus means that step exited with zero code, or that none of the chain steps failed.
+	SucceededStatus = "succeeded"
+	// FailedStatus means that step didn't start, exited with non zero code or was terminated,
+	// or that any of the chain steps failed.
+	FailedStatus = "failed"
+	// SkippedStatus means that step didn't run as its condition wasn't met.
+	SkippedStatus = "skipped"
+)
+
+var (
+	prevChainID uint64
+
+	// in memory storage of running & finished chains, chains are not persisted.
+	// The finished chain is removed along with its last step process, the finished chain
+	// which step processes are all removed is removed by the cleaner as dead processes
+	chains = &chainsMap{items: make(map[uint64]*Chain)}
+)
+
+// ChainStep is the command executed by the chain when its condition is met.
+type ChainStep struct {
+	Command
+
+	// The condition of the step, one of RunOnSuccess, RunOnFailure, RunAlways.
+	// If empty then the step runs on success.
+	Condition string `json:"condition,omitempty"`
+}
+
+// Chain runs the processes of its steps sequentially, each step
+// starts after the previous one dies if the step condition is met.
+type Chain struct {
+	// The id of the chain, the processes of the steps refer to it by MachineProcess.ParentChain.
+	ID uint64 `json:"id"`
+
+	// The aggregate status of the chain, one of RunningStatus, SucceededStatus, FailedStatus.
+	Status string `json:"status"`
+
+	// The statuses of the steps in the order of their execution.
+	Steps []ChainStepStatus `json:"steps"`
+
+	// The steps which are executed by the chain.
+	steps []ChainStep
+
+	// Subscribed to the events of each step process.
+	subs []Subscriber
+
+	// The time when the last step of the chain is done.
+	finishTime time.Time
+
+	// Chain mutex should be used to sync chain data.
+	mutex *sync.RWMutex
+}
+
+// ChainStepStatus describes the execution of the chain step.
+type ChainStepStatus struct {
+	// The name of the step command.
+	Name string `json:"name"`
+
+	// The condition of the step.
+	Condition string `json:"condition"`
+
+	// The status of the step, one of PendingStatus, RunningStatus,
+	// SucceededStatus, FailedStatus, SkippedStatus.
+	Status string `json:"status"`
+
+	// The pid of the step process, the value is zero until the step is started.
+	Pid uint64 `json:"pid,omitempty"`
+
+	// The exit code of the step process, the value is -1 until the step process dies.
+	ExitCode int `json:"exitCode"`
+
+	// The reason why the step process couldn't be started.
+	Error string `json:"error,omitempty"`
+}
+
+// NoChainError is returned when chain that is target of an action doesn't exist.
+type NoChainError struct {
+	error
+	ID uint64
+}
+
+// Lockable map for storing chains.
+type chainsMap struct {
+	sync.RWMutex
+	items map[uint64]*Chain
+}
+
+// CheckChainCondition checks whether the condition of chain step is valid.
+func CheckChainCondition(condition string) error {
+	switch condition {
+	case "", RunOnSuccess, RunOnFailure, RunAlways:
+		return nil
+	}
+	return fmt.Errorf("Chain step condition '%s' is not supported", condition)
+}
+
+// StartChain starts the chain of given steps, the steps are executed sequentially in background.
+// Subscribers are subscribed to the events of each step process.
+// Returns the chain with the first step running.
+func StartChain(steps []ChainStep, subs ...Subscriber) (Chain, error) {
+	if len(steps) == 0 {
+		return Chain{}, errors.New("Chain must have at least one step")
+	}
+	statuses := make([]ChainStepStatus, len(steps))
+	for i, step := range steps {
+		if err := CheckChainCondition(step.Condition); err != nil {
+			return Chain{}, err
+		}
+		condition := step.Condition
+		if condition == "" {
+			condition = RunOnSuccess
+		}
+		statuses[i] = ChainStepStatus{
+			Name:      step.Name,
+			Condition: condition,
+			Status:    PendingStatus,
+			ExitCode:  -1,
+		}
+	}
+	chain := &Chain{
+		ID:     atomic.AddUint64(&prevChainID, 1),
+		Status: RunningStatus,
+		Steps:  statuses,
+		steps:  steps,
+		subs:   subs,
+		mutex:  &sync.RWMutex{},
+	}
+
+	chains.Lock()
+	chains.items[chain.ID] = chain
+	chains.Unlock()
+
+	// the first step is started synchronously, so the caller
+	// knows its pid and subscribers don't miss its events
+	pid, failed := chain.startStep(0, false)
+	go chain.run(pid, failed)
+
+	return chain.snapshot(), nil
+}
+
+// GetChain retrieves chain by id.
+// If chain doesn't exist then error of type NoChainError is returned.
+func GetChain(id uint64) (Chain, error) {
+	chains.RLock()
+	chain, ok := chains.items[id]
+	chains.RUnlock()
+	if !ok {
+		return Chain{}, noChain(id)
+	}
+	return chain.snapshot(), nil
+}
+
+// Waits for the step process to die and starts the next steps until all of them are done.
+func (chain *Chain) run(pid uint64, failed bool) {
+	for i := range chain.steps {
+		if pid != 0 {
+			failed = chain.waitStep(i, pid) || failed
+		}
+		if i+1 < len(chain.steps) {
+			var stepFailed bool
+			pid, stepFailed = chain.startStep(i+1, failed)
+			failed = stepFailed || failed
+		}
+	}
+
+	chain.mutex.Lock()
+	chain.Status = SucceededStatus
+	if failed {
+		chain.Status = FailedStatus
+	}
+	chain.finishTime = time.Now()
+	chain.mutex.Unlock()
+}
+
+// Starts the step process if the step condition is met.
+// Returns the pid of the started process, zero if the step is not started,
+// and whether the step failed to start.
+func (chain *Chain) startStep(idx int, failed bool) (uint64, bool) {
+	condition := chain.Steps[idx].Condition
+	if (condition == RunOnSuccess && failed) || (condition == RunOnFailure && !failed) {
+		chain.mutex.Lock()
+		chain.Steps[idx].Status = SkippedStatus
+		chain.mutex.Unlock()
+		return 0, false
+	}
+
+	p := NewBuilder().Cmd(chain.steps[idx].Command).Build()
+	p.ParentChain = chain.ID
+	for _, sub := range chain.subs {
+		// each process has its own copy, as subscribers of the process may be updated
+		stepSub := sub
+		p.subs = append(p.subs, &stepSub)
+	}
+	started, err := Start(p)
+
+	chain.mutex.Lock()
+	defer chain.mutex.Unlock()
+	if err != nil {
+		chain.Steps[idx].Status = FailedStatus
+		chain.Steps[idx].Error = err.Error()
+		return 0, true
+	}
+	chain.Steps[idx].Status = RunningStatus
+	chain.Steps[idx].Pid = started.Pid
+	return started.Pid, false
+}
+
+// Waits until the step process dies, returns whether the step failed.
+func (chain *Chain) waitStep(idx int, pid uint64) bool {
+	p, err := Wait(pid, 0)
+	failed := err != nil || p.ExitCode != 0 || p.Reason != ExitedReason
+
+	chain.mutex.Lock()
+	defer chain.mutex.Unlock()
+	if err != nil {
+		// the process was removed by the cleaner right after its death
+		chain.Steps[idx].Error = err.Error()
+	} else {
+		chain.Steps[idx].ExitCode = p.ExitCode
+	}
+	chain.Steps[idx].Status = SucceededStatus
+	if failed {
+		chain.Steps[idx].Status = FailedStatus
+	}
+	return failed
+}
+
+// Removes the finished chain if none of its step processes is kept, processes must be locked.
+func removeChainIfForgotten(id uint64) {
+	chains.Lock()
+	defer chains.Unlock()
+	chain, ok := chains.items[id]
+	if !ok {
+		return
+	}
+	chain.mutex.RLock()
+	defer chain.mutex.RUnlock()
+	if chain.Status != RunningStatus && !chain.hasStepProcesses() {
+		delete(chains.items, id)
+	}
+}
+
+// Removes the chains finished before the bound which step processes are all removed,
+// processes must be locked.
+func removeChainsFinishedBefore(bound time.Time) {
+	chains.Lock()
+	defer chains.Unlock()
+	for id, chain := range chains.items {
+		chain.mutex.RLock()
+		if chain.Status != RunningStatus && chain.finishTime.Before(bound) && !chain.hasStepProcesses() {
+			delete(chains.items, id)
+		}
+		chain.mutex.RUnlock()
+	}
+}
+
+// Returns whether any of the chain step processes is kept, processes and chain must be locked.
+func (chain *Chain) hasStepProcesses() bool {
+	for _, step := range chain.Steps {
+		if _, ok := processes.items[step.Pid]; ok && step.Pid != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (chain *Chain) snapshot() Chain {
+	chain.mutex.RLock()
+	defer chain.mutex.RUnlock()
+	snapshot := Chain{
+		ID:     chain.ID,
+		Status: chain.Status,
+		Steps:  make([]ChainStepStatus, len(chain.Steps)),
+	}
+	copy(snapshot.Steps, chain.Steps)
+	return snapshot
+}
+
+// Returns an error indicating that chain with given id doesn't exist
+func noChain(id uint64) *NoChainError {
+	return &NoChainError{
+		error: fmt.Errorf("Chain with id '%d' does not exist", id),
+		ID:    id,
+	}
+}
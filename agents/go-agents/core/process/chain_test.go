@@ -0,0 +1,172 @@
+//
+// Copyright (c) 2012-2017 Codenvy, S.A.
+// All rights reserved. This program and the accompanying materials
+// are made available under the terms of the Eclipse Public License v1.0
+// which accompanies this distribution, and is available at
+// http://www.eclipse.org/legal/epl-v10.html
+//
+// Contributors:
+//   Codenvy, S.A. - initial API and implementation
+//
+
+package process_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eclipse/che/agents/go-agents/core/process"
+)
+
+func TestChainRunsStepsAccordingToConditions(t *testing.T) {
+	chain, err := process.StartChain([]process.ChainStep{
+		{Command: process.Command{Name: "build", CommandLine: "exit 3"}},
+		{Command: process.Command{Name: "run", CommandLine: "echo run"}},
+		{Command: process.Command{Name: "report", CommandLine: "echo report"}, Condition: process.RunOnFailure},
+		{Command: process.Command{Name: "cleanup", CommandLine: "echo cleanup"}, Condition: process.RunAlways},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chain.Status != process.RunningStatus || chain.Steps[0].Pid == 0 {
+		t.Fatalf("Expected chain to be running its first step, but got %v", chain)
+	}
+
+	chain = waitChain(t, chain.ID)
+	if chain.Status != process.FailedStatus {
+		t.Fatalf("Expected chain to fail, but its status is '%s'", chain.Status)
+	}
+	expected := []string{process.FailedStatus, process.SkippedStatus, process.SucceededStatus, process.SucceededStatus}
+	for i, step := range chain.Steps {
+		if step.Status != expected[i] {
+			t.Fatalf("Expected step '%s' status to be '%s', but it is '%s'", step.Name, expected[i], step.Status)
+		}
+	}
+	if chain.Steps[0].ExitCode != 3 || chain.Steps[1].Pid != 0 {
+		t.Fatalf("Unexpected steps %v", chain.Steps)
+	}
+
+	p, err := process.Get(chain.Steps[3].Pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ParentChain != chain.ID {
+		t.Fatalf("Expected step process to refer to chain %d, but it refers to %d", chain.ID, p.ParentChain)
+	}
+}
+
+func TestChainSucceedsIfNoStepFailed(t *testing.T) {
+	chain, err := process.StartChain([]process.ChainStep{
+		{Command: process.Command{Name: "build", CommandLine: "echo build"}},
+		{Command: process.Command{Name: "report", CommandLine: "echo report"}, Condition: process.RunOnFailure},
+		{Command: process.Command{Name: "run", CommandLine: "echo run"}, Condition: process.RunOnSuccess},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain = waitChain(t, chain.ID)
+	if chain.Status != process.SucceededStatus {
+		t.Fatalf("Expected chain to succeed, but its status is '%s'", chain.Status)
+	}
+	if chain.Steps[1].Status != process.SkippedStatus || chain.Steps[2].ExitCode != 0 {
+		t.Fatalf("Unexpected steps %v", chain.Steps)
+	}
+}
+
+func TestStartChainFailsIfConditionIsInvalid(t *testing.T) {
+	steps := []process.ChainStep{{Command: process.Command{Name: "build", CommandLine: "echo build"}}}
+	first, err := process.StartChain(steps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = process.StartChain([]process.ChainStep{
+		{Command: process.Command{Name: "build", CommandLine: "echo build"}, Condition: "on-timeout"},
+	})
+	if err == nil {
+		t.Fatal("Expected chain with invalid condition not to start")
+	}
+	second, err := process.StartChain(steps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.ID != first.ID+1 {
+		t.Fatalf("Expected invalid chain not to take an id, but ids are %d and %d", first.ID, second.ID)
+	}
+}
+
+func TestGetChainFailsIfChainDoesNotExist(t *testing.T) {
+	if _, err := process.GetChain(1 << 60); err == nil {
+		t.Fatal("Expected an error when getting not existing chain")
+	} else if _, ok := err.(*process.NoChainError); !ok {
+		t.Fatalf("Expected error of type NoChainError, but got %v", err)
+	}
+}
+
+func TestChainIsRemovedAlongWithItsLastStepProcess(t *testing.T) {
+	chain, err := process.StartChain([]process.ChainStep{
+		{Command: process.Command{Name: "build", CommandLine: "echo build"}},
+		{Command: process.Command{Name: "report", CommandLine: "echo report"}, Condition: process.RunOnFailure},
+		{Command: process.Command{Name: "run", CommandLine: "echo run"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain = waitChain(t, chain.ID)
+
+	if err := process.Forget(chain.Steps[0].Pid); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := process.GetChain(chain.ID); err != nil {
+		t.Fatalf("Expected chain to be kept while its step processes are kept, but got %s", err)
+	}
+	if err := process.Forget(chain.Steps[2].Pid); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := process.GetChain(chain.ID); err == nil {
+		t.Fatal("Expected chain to be removed along with its last step process")
+	}
+}
+
+func TestFinishedChainIsKeptUntilCleanedUpIfNoStepProcessIsKept(t *testing.T) {
+	chain, err := process.StartChain([]process.ChainStep{
+		{Command: process.Command{Name: "build", CommandLine: "echo build", WorkingDir: "/not/existing/dir"}},
+		{Command: process.Command{Name: "run", CommandLine: "echo run"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain = waitChain(t, chain.ID)
+	if chain.Status != process.FailedStatus || chain.Steps[0].Pid != 0 || chain.Steps[1].Status != process.SkippedStatus {
+		t.Fatalf("Expected chain without step processes to fail, but got %v", chain)
+	}
+
+	cleaner := process.NewCleaner(1, 1)
+	cleaner.CleanOnce()
+	if _, err := process.GetChain(chain.ID); err != nil {
+		t.Fatalf("Expected finished chain to be kept until cleanup threshold, but got %s", err)
+	}
+
+	cleaner.CleanupThreshold = 0
+	cleaner.CleanOnce()
+	if _, err := process.GetChain(chain.ID); err == nil {
+		t.Fatal("Expected finished chain to be removed by the cleaner after cleanup threshold")
+	}
+}
+
+func waitChain(t *testing.T, id uint64) process.Chain {
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		chain, err := process.GetChain(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if chain.Status != process.RunningStatus {
+			return chain
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Chain %d doesn't finish in 2 seconds", id)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
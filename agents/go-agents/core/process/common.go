@@ -35,6 +35,10 @@ type LogMessage struct {
 	Kind LogKind   `json:"kind"`
 	Time time.Time `json:"time"`
 	Text string    `json:"text"`
+
+	// The output mode the text was captured in, see OutputOptions.
+	// Empty mode means LinesOutputMode.
+	Mode string `json:"mode,omitempty"`
 }
 
 // ParseTime parses string into Time.
@@ -17,10 +17,31 @@ import (
 
 // Types of process events.
 const (
-	StartedEventType = "process_started"
-	DiedEventType    = "process_died"
-	StdoutEventType  = "process_stdout"
-	StderrEventType  = "process_stderr"
+	StartedEventType    = "process_started"
+	DiedEventType       = "process_died"
+	RestartingEventType = "process_restarting"
+	ReadyEventType      = "process_ready"
+	NotReadyEventType   = "process_not_ready"
+	StatsEventType      = "process_stats"
+	StdoutEventType     = "process_stdout"
+	StderrEventType     = "process_stderr"
+)
+
+// Reasons of the process death.
+const (
+	// ExitedReason means that process exited by itself.
+	ExitedReason = "exited"
+	// KilledReason means that process was killed with SIGKILL.
+	KilledReason = "killed"
+	// SignaledReason means that process was terminated by a signal different from SIGKILL.
+	SignaledReason = "signaled"
+	// TimeoutReason means that process was terminated as its timeout elapsed.
+	TimeoutReason = "timeout"
+	// OOMReason means that process was killed as it exceeded its memory limit.
+	OOMReason = "oom"
+	// LostReason means that process was alive when the agent stopped,
+	// and its state was restored from the registry after the agent restart.
+	LostReason = "lost"
 )
 
 // Event is a common interface for all the process events.
@@ -33,13 +54,17 @@ type EventConsumer interface {
 	Accept(event Event)
 }
 
-// StartedEvent published once when process is started.
+// StartedEvent published when process is started and each time when it is restarted.
 type StartedEvent struct {
-	Time        time.Time `json:"time"`
-	Pid         uint64    `json:"pid"`
-	NativePid   int       `json:"nativePid"`
-	Name        string    `json:"name"`
-	CommandLine string    `json:"commandLine"`
+	Time         time.Time         `json:"time"`
+	Pid          uint64            `json:"pid"`
+	NativePid    int               `json:"nativePid"`
+	Name         string            `json:"name"`
+	CommandLine  string            `json:"commandLine"`
+	Env          map[string]string `json:"env,omitempty"`
+	CleanEnv     bool              `json:"cleanEnv,omitempty"`
+	WorkingDir   string            `json:"workingDir,omitempty"`
+	RestartCount int               `json:"restartCount"`
 }
 
 // Type returns StartedEventType.
@@ -47,11 +72,15 @@ func (se *StartedEvent) Type() string { return StartedEventType }
 
 func newStartedEvent(mp MachineProcess) *StartedEvent {
 	return &StartedEvent{
-		Time:        time.Now(),
-		Pid:         mp.Pid,
-		NativePid:   mp.NativePid,
-		Name:        mp.Name,
-		CommandLine: mp.CommandLine,
+		Time:         time.Now(),
+		Pid:          mp.Pid,
+		NativePid:    mp.NativePid,
+		Name:         mp.Name,
+		CommandLine:  mp.CommandLine,
+		Env:          mp.Env,
+		CleanEnv:     mp.CleanEnv,
+		WorkingDir:   mp.WorkingDir,
+		RestartCount: mp.RestartCount,
 	}
 }
 
@@ -63,6 +92,8 @@ type DiedEvent struct {
 	Name        string    `json:"name"`
 	CommandLine string    `json:"commandLine"`
 	ExitCode    int       `json:"exitCode"`
+	Signal      string    `json:"signal,omitempty"`
+	Reason      string    `json:"reason"`
 }
 
 // Type returns DiedEventType.
@@ -76,35 +107,113 @@ func newDiedEvent(mp MachineProcess) *DiedEvent {
 		Name:        mp.Name,
 		CommandLine: mp.CommandLine,
 		ExitCode:    mp.ExitCode,
+		Signal:      mp.Signal,
+		Reason:      mp.Reason,
 	}
 }
 
+// RestartingEvent published each time when the native process of a process
+// with restart policy dies and the process is going to be restarted.
+type RestartingEvent struct {
+	Time         time.Time `json:"time"`
+	Pid          uint64    `json:"pid"`
+	NativePid    int       `json:"nativePid"`
+	Name         string    `json:"name"`
+	CommandLine  string    `json:"commandLine"`
+	ExitCode     int       `json:"exitCode"`
+	Signal       string    `json:"signal,omitempty"`
+	RestartCount int       `json:"restartCount"`
+	Delay        int64     `json:"delay"`
+}
+
+// Type returns RestartingEventType.
+func (re *RestartingEvent) Type() string { return RestartingEventType }
+
+func newRestartingEvent(mp MachineProcess, exitCode int, signal string, delay time.Duration) *RestartingEvent {
+	return &RestartingEvent{
+		Time:         time.Now(),
+		Pid:          mp.Pid,
+		NativePid:    mp.NativePid,
+		Name:         mp.Name,
+		CommandLine:  mp.CommandLine,
+		ExitCode:     exitCode,
+		Signal:       signal,
+		RestartCount: mp.RestartCount + 1,
+		Delay:        int64(delay / time.Millisecond),
+	}
+}
+
+// ReadinessEvent is published once when process becomes ready according
+// to its readiness probe or when it is not ready in time.
+type ReadinessEvent struct {
+	Time      time.Time `json:"time"`
+	Pid       uint64    `json:"pid"`
+	NativePid int       `json:"nativePid"`
+	Name      string    `json:"name"`
+	Text      string    `json:"text"`
+
+	_type string
+}
+
+// Type returns one of ReadyEventType, NotReadyEventType.
+func (re *ReadinessEvent) Type() string { return re._type }
+
+func newReadinessEvent(mp MachineProcess, ready bool, text string) *ReadinessEvent {
+	eventType := NotReadyEventType
+	if ready {
+		eventType = ReadyEventType
+	}
+	return &ReadinessEvent{
+		Time:      time.Now(),
+		Pid:       mp.Pid,
+		NativePid: mp.NativePid,
+		Name:      mp.Name,
+		Text:      text,
+		_type:     eventType,
+	}
+}
+
+// StatsEvent is published periodically while process is alive
+// to the subscribers interested in process resources usage.
+type StatsEvent struct {
+	Stats
+}
+
+// Type returns StatsEventType.
+func (se *StatsEvent) Type() string { return StatsEventType }
+
 // OutputEvent is published each time when process writes to stdout or stderr.
 type OutputEvent struct {
 	Time time.Time `json:"time"`
 	Pid  uint64    `json:"pid"`
 	Text string    `json:"text"`
 
+	// The output mode the text was captured in, see OutputOptions.
+	// Empty mode means LinesOutputMode, the text of RawOutputMode is encoded by base64.
+	Mode string `json:"mode,omitempty"`
+
 	_type string
 }
 
 // Type returns one of StdoutEventType, StderrEventType.
 func (se *OutputEvent) Type() string { return se._type }
 
-func newStderrEvent(pid uint64, text string, when time.Time) Event {
+func newStderrEvent(pid uint64, text string, mode string, when time.Time) Event {
 	return &OutputEvent{
 		Time:  when,
 		Pid:   pid,
 		Text:  text,
+		Mode:  mode,
 		_type: StderrEventType,
 	}
 }
 
-func newStdoutEvent(pid uint64, text string, when time.Time) Event {
+func newStdoutEvent(pid uint64, text string, mode string, when time.Time) Event {
 	return &OutputEvent{
 		Time:  when,
 		Pid:   pid,
 		Text:  text,
+		Mode:  mode,
 		_type: StdoutEventType,
 	}
 }
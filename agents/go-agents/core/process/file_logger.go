@@ -30,11 +30,34 @@ type FileLogger struct {
 	filename string
 	buffer   *bytes.Buffer
 	encoder  *json.Encoder
+
+	// the size and the number of lines of the flushed logs
+	offset int64
+	lines  int64
+
+	// the time of the first buffered line and the number of buffered lines
+	bufferedTime  time.Time
+	bufferedLines int64
+
+	// the offset of the latest index entry, -1 if nothing is indexed yet
+	indexedOffset int64
+
+	// whether the logs are not indexed anymore as the index is inconsistent with the file
+	indexBroken bool
+
+	// the time when the logs were flushed into the current file for the first time
+	segmentTime time.Time
+
+	// the sequence number of the latest rotated segment, 0 if the file wasn't rotated
+	segments int
+
+	// the time before which the file is not rotated as the previous rotation failed
+	rotationRetryTime time.Time
 }
 
 // NewLogger creates FileLogger instance by provided log file path
 func NewLogger(filename string) (*FileLogger, error) {
-	fl := &FileLogger{filename: filename}
+	fl := &FileLogger{filename: filename, indexedOffset: -1}
 	fl.buffer = &bytes.Buffer{}
 	fl.encoder = json.NewEncoder(fl.buffer)
 
@@ -45,6 +68,14 @@ func NewLogger(filename string) (*FileLogger, error) {
 	}
 	defer closeFile(file)
 
+	// the index and the segments of the truncated file are not valid anymore
+	if err := os.Remove(indexFilename(filename)); err != nil && !os.IsNotExist(err) {
+		log.Printf("Couldn't remove logs index '%s'. %s \n", indexFilename(filename), err.Error())
+	}
+	for _, segment := range listSegments(filename) {
+		removeSegment(segment)
+	}
+
 	return fl, nil
 }
 
@@ -57,12 +88,12 @@ func (fl *FileLogger) Flush() {
 
 // OnStdout writes log message and marks it as stdout message
 func (fl *FileLogger) OnStdout(line string, time time.Time) {
-	fl.writeLine(&LogMessage{StdoutKind, time, line})
+	fl.writeLine(&LogMessage{Kind: StdoutKind, Time: time, Text: line})
 }
 
 // OnStderr writes log message and marks it as stdout message
 func (fl *FileLogger) OnStderr(line string, time time.Time) {
-	fl.writeLine(&LogMessage{StderrKind, time, line})
+	fl.writeLine(&LogMessage{Kind: StderrKind, Time: time, Text: line})
 }
 
 // Close writes buffered data into file and closes it
@@ -75,8 +106,13 @@ func (fl *FileLogger) writeLine(message *LogMessage) {
 	err := fl.encoder.Encode(message)
 	if err != nil {
 		log.Printf("Error appears on writing data to logs buffer. %s \n", err.Error())
+	} else {
+		if fl.bufferedLines == 0 {
+			fl.bufferedTime = message.Time
+		}
+		fl.bufferedLines++
 	}
-	if flushThreshold < fl.buffer.Len() {
+	if flushThreshold < fl.buffer.Len() || fl.rotationNeeded() {
 		fl.doFlush()
 	}
 	fl.Unlock()
@@ -89,14 +125,46 @@ func (fl *FileLogger) doFlush() {
 			log.Printf("Couldn't open file '%s' for flushing the buffer. %s \n", fl.filename, err.Error())
 		} else {
 			defer closeFile(f)
-			_, err = fl.buffer.WriteTo(f)
+			if fl.offset == 0 {
+				fl.segmentTime = time.Now()
+			}
+			n, err := fl.buffer.WriteTo(f)
 			if err != nil {
 				log.Printf("Error appears on flushing data to file '%s'. %s \n", fl.filename, err.Error())
+				fl.breakIndex()
+			} else {
+				fl.index()
+			}
+			fl.offset += n
+			fl.lines += fl.bufferedLines
+			fl.bufferedLines = 0
+			if fl.rotationNeeded() {
+				fl.rotate()
 			}
 		}
 	}
 }
 
+// Indexes the just flushed lines, if the latest index entry is far enough from them.
+func (fl *FileLogger) index() {
+	if fl.indexBroken || (fl.indexedOffset >= 0 && fl.offset-fl.indexedOffset < indexInterval) {
+		return
+	}
+	entry := logsIndexEntry{Time: fl.bufferedTime, Line: fl.lines, Offset: fl.offset}
+	if err := appendIndexEntry(fl.filename, entry); err != nil {
+		log.Printf("Error appears on writing index of file '%s'. %s \n", fl.filename, err.Error())
+		fl.breakIndex()
+		return
+	}
+	fl.indexedOffset = fl.offset
+}
+
+// Stops indexing and removes the index, so it's rebuilt by the next reader.
+func (fl *FileLogger) breakIndex() {
+	fl.indexBroken = true
+	os.Remove(indexFilename(fl.filename))
+}
+
 func closeFile(file *os.File) {
 	if err := file.Close(); err != nil {
 		log.Printf("Can't close file %s. Error: %s", file.Name(), err)
@@ -114,4 +114,5 @@ func removeFile(path string) {
 	if err := os.Remove(path); err != nil {
 		log.Printf("Can't remove file %s. Error: %s", path, err)
 	}
+	os.Remove(path + process.LogsIndexSuffix)
 }
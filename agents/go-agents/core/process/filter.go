@@ -0,0 +1,199 @@
+//
+// Copyright (c) 2012-2017 Codenvy, S.A.
+// All rights reserved. This program and the accompanying materials
+// are made available under the terms of the Eclipse Public License v1.0
+// which accompanies this distribution, and is available at
+// http://www.eclipse.org/legal/epl-v10.html
+//
+// Contributors:
+//   Codenvy, S.A. - initial API and implementation
+//
+
+package process
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Fields processes may be sorted by.
+const (
+	// SortByPid sorts processes by pid, this is the default order.
+	SortByPid = "pid"
+	// SortByName sorts processes by name.
+	SortByName = "name"
+	// SortByStartTime sorts processes by the time they were started.
+	SortByStartTime = "startTime"
+)
+
+// Filter defines which processes are listed and in which order.
+// Zero value of the filter lists all the processes sorted by pid.
+type Filter struct {
+	// Labels the process must have, see ParseSelector.
+	Selector Selector
+
+	// The name of the process, empty matches any name.
+	Name string
+
+	// The type of the process, empty matches any type.
+	Type string
+
+	// If set then only alive or only dead processes are listed.
+	Alive *bool
+
+	// If not zero then only processes started after or before these times are listed.
+	StartedAfter  time.Time
+	StartedBefore time.Time
+
+	// One of SortByPid, SortByName, SortByStartTime, empty means SortByPid.
+	SortBy string
+
+	// Whether processes are listed in the descending order.
+	Desc bool
+
+	// The number of processes to skip and the max number of processes to list,
+	// zero limit means no limit.
+	Skip  int
+	Limit int
+}
+
+// Selector is a set of label requirements, the process matches
+// the selector if its labels satisfy all the requirements.
+type Selector []LabelRequirement
+
+// LabelRequirement requires the label either to exist
+// or to be equal or not equal to the value.
+type LabelRequirement struct {
+	Key   string
+	Value string
+
+	// If true then label must exist regardless of its value.
+	Exists bool
+
+	// If true then label must not exist or must have a different value.
+	NotEqual bool
+}
+
+// ParseSelector parses comma separated label requirements,
+// e.g. 'panel=debug,!temporary,owner!=ide,keep'. Each requirement is one of:
+// 'key=value' - label must be equal to the value,
+// 'key!=value' - label must not be equal to the value,
+// 'key' - label must exist, '!key' - label must not exist.
+func ParseSelector(selector string) (Selector, error) {
+	result := Selector{}
+	for _, item := range strings.Split(selector, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		var req LabelRequirement
+		if idx := strings.Index(item, "!="); idx >= 0 {
+			req = LabelRequirement{Key: item[:idx], Value: item[idx+2:], NotEqual: true}
+		} else if idx := strings.IndexByte(item, '='); idx >= 0 {
+			req = LabelRequirement{Key: item[:idx], Value: item[idx+1:]}
+		} else if strings.HasPrefix(item, "!") {
+			req = LabelRequirement{Key: item[1:], Exists: true, NotEqual: true}
+		} else {
+			req = LabelRequirement{Key: item, Exists: true}
+		}
+		req.Key = strings.TrimSpace(req.Key)
+		req.Value = strings.TrimSpace(req.Value)
+		if req.Key == "" {
+			return nil, fmt.Errorf("Label requirement '%s' is not valid", item)
+		}
+		result = append(result, req)
+	}
+	return result, nil
+}
+
+// Matches checks whether labels satisfy all the selector requirements.
+func (selector Selector) Matches(labels map[string]string) bool {
+	for _, req := range selector {
+		value, ok := labels[req.Key]
+		matches := ok && (req.Exists || value == req.Value)
+		if matches == req.NotEqual {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate checks whether the filter is valid.
+func (filter *Filter) Validate() error {
+	switch filter.SortBy {
+	case "", SortByPid, SortByName, SortByStartTime:
+	default:
+		return fmt.Errorf("Sorting by '%s' is not supported", filter.SortBy)
+	}
+	if filter.Skip < 0 {
+		return errors.New("Required 'skip' to be >= 0")
+	}
+	if filter.Limit < 0 {
+		return errors.New("Required 'limit' to be >= 0")
+	}
+	return nil
+}
+
+// Checks whether the process matches the filter, the process must be locked.
+func (filter *Filter) matches(p *MachineProcess) bool {
+	if filter.Name != "" && p.Name != filter.Name {
+		return false
+	}
+	if filter.Type != "" && p.Type != filter.Type {
+		return false
+	}
+	if filter.Alive != nil && p.Alive != *filter.Alive {
+		return false
+	}
+	if !filter.StartedAfter.IsZero() && !p.startTime.After(filter.StartedAfter) {
+		return false
+	}
+	if !filter.StartedBefore.IsZero() && !p.startTime.Before(filter.StartedBefore) {
+		return false
+	}
+	return filter.Selector.Matches(p.Labels)
+}
+
+// Sorts and pages snapshots of the processes matched by the filter.
+func (filter *Filter) apply(items []MachineProcess) []MachineProcess {
+	var less func(p1, p2 *MachineProcess) bool
+	switch filter.SortBy {
+	case SortByName:
+		less = func(p1, p2 *MachineProcess) bool {
+			return p1.Name < p2.Name || (p1.Name == p2.Name && p1.Pid < p2.Pid)
+		}
+	case SortByStartTime:
+		less = func(p1, p2 *MachineProcess) bool {
+			return p1.startTime.Before(p2.startTime) || (p1.startTime.Equal(p2.startTime) && p1.Pid < p2.Pid)
+		}
+	default:
+		less = func(p1, p2 *MachineProcess) bool { return p1.Pid < p2.Pid }
+	}
+	if filter.Desc {
+		asc := less
+		less = func(p1, p2 *MachineProcess) bool { return asc(p2, p1) }
+	}
+	sort.Sort(&processesSorter{items, less})
+
+	if filter.Skip >= len(items) {
+		return []MachineProcess{}
+	}
+	items = items[filter.Skip:]
+	if filter.Limit > 0 && filter.Limit < len(items) {
+		items = items[:filter.Limit]
+	}
+	return items
+}
+
+// Sorts processes by the given less function.
+type processesSorter struct {
+	items []MachineProcess
+	less  func(p1, p2 *MachineProcess) bool
+}
+
+func (s *processesSorter) Len() int           { return len(s.items) }
+func (s *processesSorter) Swap(i, j int)      { s.items[i], s.items[j] = s.items[j], s.items[i] }
+func (s *processesSorter) Less(i, j int) bool { return s.less(&s.items[i], &s.items[j]) }
@@ -0,0 +1,81 @@
+//
+// Copyright (c) 2012-2017 Codenvy, S.A.
+// All rights reserved. This program and the accompanying materials
+// are made available under the terms of the Eclipse Public License v1.0
+// which accompanies this distribution, and is available at
+// http://www.eclipse.org/legal/epl-v10.html
+//
+// Contributors:
+//   Codenvy, S.A. - initial API and implementation
+//
+
+package process
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// DefaultInputWriteTimeout is how long writing to the process input may take,
+// after this time the write fails as the process doesn't read its input.
+const DefaultInputWriteTimeout = 10 * time.Second
+
+var (
+	// how long writing to the process input may take
+	inputWriteTimeout = DefaultInputWriteTimeout
+)
+
+// SetInputWriteTimeout changes the time writing to the process input may take.
+func SetInputWriteTimeout(timeout time.Duration) {
+	if timeout > 0 {
+		inputWriteTimeout = timeout
+	}
+}
+
+// Writes the process input in background, so the process which doesn't
+// read its input never blocks the writer for longer than the timeout.
+// Only one write may be in progress, the next write fails until the previous one completes.
+type timedInput struct {
+	input io.WriteCloser
+
+	// has a value while the write is in progress
+	busy chan bool
+}
+
+func newTimedInput(input io.WriteCloser) *timedInput {
+	return &timedInput{input: input, busy: make(chan bool, 1)}
+}
+
+func (in *timedInput) Write(p []byte) (int, error) {
+	select {
+	case in.busy <- true:
+	default:
+		return 0, errors.New("The previous input is still being written")
+	}
+	type result struct {
+		n   int
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		n, err := in.input.Write(p)
+		<-in.busy
+		done <- result{n, err}
+	}()
+
+	timer := time.NewTimer(inputWriteTimeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.n, r.err
+	case <-timer.C:
+		return 0, fmt.Errorf("The input isn't read by the process in %s", inputWriteTimeout)
+	}
+}
+
+// Close closes the input, the write in progress if any is interrupted.
+func (in *timedInput) Close() error {
+	return in.input.Close()
+}
@@ -0,0 +1,102 @@
+//
+// Copyright (c) 2012-2017 Codenvy, S.A.
+// All rights reserved. This program and the accompanying materials
+// are made available under the terms of the Eclipse Public License v1.0
+// which accompanies this distribution, and is available at
+// http://www.eclipse.org/legal/epl-v10.html
+//
+// Contributors:
+//   Codenvy, S.A. - initial API and implementation
+//
+
+package process
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KnownInterpreters are the interpreters which may be allowed by their names only,
+// each of them is the program followed by the option which makes it execute the command line.
+var KnownInterpreters = map[string][]string{
+	"bash":   {"bash", "-c"},
+	"sh":     {"sh", "-c"},
+	"zsh":    {"zsh", "-c"},
+	"python": {"python", "-c"},
+	"node":   {"node", "-e"},
+}
+
+var (
+	// the interpreters commands may specify, the key is the name of the interpreter
+	// and the value is the program with its options the command line is appended to,
+	// if empty then commands are executed only by the default shell interpreter
+	allowedInterpreters = map[string][]string{}
+)
+
+// SetAllowedInterpreters sets the interpreters commands may specify.
+// Each spec is either the name of one of KnownInterpreters e.g. 'python',
+// or the name followed by '=' and the program with its options e.g. 'python3=/usr/bin/python3 -c'.
+func SetAllowedInterpreters(specs []string) error {
+	allowed := map[string][]string{}
+	for _, spec := range specs {
+		if spec = strings.TrimSpace(spec); spec == "" {
+			continue
+		}
+		name, program := spec, ""
+		if idx := strings.IndexByte(spec, '='); idx >= 0 {
+			name, program = strings.TrimSpace(spec[:idx]), spec[idx+1:]
+		}
+		if name == "" {
+			return fmt.Errorf("Interpreter '%s' is not valid", spec)
+		}
+		if program == "" {
+			known, ok := KnownInterpreters[name]
+			if !ok {
+				return fmt.Errorf("Interpreter '%s' is not known, its program must be specified", name)
+			}
+			allowed[name] = known
+		} else if args := strings.Fields(program); len(args) != 0 {
+			allowed[name] = args
+		} else {
+			return fmt.Errorf("Program of interpreter '%s' is not valid", name)
+		}
+	}
+	allowedInterpreters = allowed
+	return nil
+}
+
+// CheckInterpreter checks whether commands may specify the interpreter.
+func CheckInterpreter(name string) error {
+	_, err := interpreterArgs(name)
+	return err
+}
+
+// Returns the program with its options of the allowed interpreter,
+// the default shell interpreter is used if the name is empty.
+func interpreterArgs(name string) ([]string, error) {
+	if name == "" {
+		return []string{shellInterpreter, "-c"}, nil
+	}
+	args, ok := allowedInterpreters[name]
+	if !ok {
+		return nil, fmt.Errorf("Interpreter '%s' is not allowed", name)
+	}
+	return args, nil
+}
+
+// Returns the arguments of the native process which executes the process command,
+// either the command line executed by the interpreter or the argv executed directly.
+func commandArgs(p MachineProcess) ([]string, error) {
+	if len(p.Argv) != 0 {
+		if p.Interpreter != "" {
+			return nil, errors.New("Interpreter can't be used along with argv")
+		}
+		return p.Argv, nil
+	}
+	args, err := interpreterArgs(p.Interpreter)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]string{}, args...), p.CommandLine), nil
+}
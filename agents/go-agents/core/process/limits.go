@@ -0,0 +1,436 @@
+//
+// Copyright (c) 2012-2017 Codenvy, S.A.
+// All rights reserved. This program and the accompanying materials
+// are made available under the terms of the Eclipse Public License v1.0
+// which accompanies this distribution, and is available at
+// http://www.eclipse.org/legal/epl-v10.html
+//
+// Contributors:
+//   Codenvy, S.A. - initial API and implementation
+//
+
+package process
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	// the period of cgroup cpu.max quota in microseconds
+	cpuPeriod = 100000
+
+	// RLIMIT_NPROC is not defined by syscall package
+	rlimitNproc = 6
+
+	// W_OK mode of access(2) is not defined by syscall package
+	accessWriteOK = 2
+
+	// if set then the binary is started as the limits helper, see RunLimitsHelper
+	limitsHelperEnv = "EXEC_AGENT_LIMITS_HELPER"
+
+	// the descriptor of the pipe the limits helper waits on
+	limitsHelperFd = 3
+)
+
+var (
+	// whether the binary runs the limits helper, see RunLimitsHelper
+	limitsHelperEnabled bool
+
+	// the cgroup v2 directory configured to create process cgroups under,
+	// if empty then the cgroup of the agent is used
+	limitsCgroup string
+
+	// the cgroup v2 directory under which process cgroups are created,
+	// empty if it's not delegated, then cgroupsErr describes why
+	cgroupsParent     string
+	cgroupsErr        error
+	cgroupsParentOnce sync.Once
+)
+
+// Limits defines resources available to the process and all its descendants.
+// Limits are enforced by a child cgroup of the cgroup which delegates memory, cpu
+// and pids controllers, otherwise memory and processes are limited by setrlimit.
+type Limits struct {
+	// Max memory in bytes. Enforced by cgroup memory.max,
+	// or by limiting the virtual address space if cgroups are not available.
+	Memory int64 `json:"memory,omitempty"`
+
+	// The relative cpu weight of the process in range [1, 10000], the default weight is 100.
+	// Enforced only by cgroup.
+	CPUShares int `json:"cpuShares,omitempty"`
+
+	// Max cpu time in millicores e.g. 1500 means one and a half cpu.
+	// Enforced only by cgroup.
+	CPUQuota int `json:"cpuQuota,omitempty"`
+
+	// Max number of processes. Enforced by cgroup pids.max,
+	// or by RLIMIT_NPROC which is applied per user if cgroups are not available.
+	MaxProcesses int `json:"maxProcesses,omitempty"`
+
+	// Max number of open files per native process.
+	MaxOpenFiles int `json:"maxOpenFiles,omitempty"`
+}
+
+// SetLimitsCgroup sets the cgroup v2 directory the cgroups of the limited processes
+// are created under. The cgroup must delegate memory, cpu and pids controllers,
+// so it must be either the root of the hierarchy or have no processes of its own.
+// If empty then the cgroup of the agent is used.
+func SetLimitsCgroup(dir string) {
+	limitsCgroup = strings.TrimSuffix(dir, "/")
+	cgroupsParentOnce = sync.Once{}
+}
+
+// RunLimitsHelper must be called first thing in main of the binary which starts
+// processes with limits, as the binary is started as the limits helper of such processes.
+// If the binary is started as the helper then it waits until the process is moved to its cgroup,
+// sets its rlimits and executes the program of the process, so the function never returns.
+// Otherwise the function enables the limits of the processes started by the binary.
+func RunLimitsHelper() {
+	rlimits, ok := os.LookupEnv(limitsHelperEnv)
+	if !ok {
+		limitsHelperEnabled = true
+		return
+	}
+	os.Unsetenv(limitsHelperEnv)
+	if len(os.Args) < 3 {
+		exitLimitsHelper(errors.New("Limits helper requires the program and its arguments"))
+	}
+
+	// everything the exec needs is allocated before the rlimits are set,
+	// as the helper may be unable to allocate memory afterwards
+	resources, values, err := parseRlimits(rlimits)
+	if err != nil {
+		exitLimitsHelper(err)
+	}
+	path, err := syscall.BytePtrFromString(os.Args[1])
+	if err != nil {
+		exitLimitsHelper(err)
+	}
+	argv, err := syscall.SlicePtrFromStrings(os.Args[2:])
+	if err != nil {
+		exitLimitsHelper(err)
+	}
+	envv, err := syscall.SlicePtrFromStrings(os.Environ())
+	if err != nil {
+		exitLimitsHelper(err)
+	}
+
+	gate := os.NewFile(limitsHelperFd, "limits-gate")
+	gate.Read(make([]byte, 1))
+	gate.Close()
+
+	runtime.LockOSThread()
+	for i := range resources {
+		if errno := setRlimit(0, resources[i], values[i]); errno != 0 {
+			exitLimitsHelper(fmt.Errorf("Couldn't set limit '%d'. %s", resources[i], errno))
+		}
+	}
+	_, _, errno := syscall.RawSyscall(syscall.SYS_EXECVE,
+		uintptr(unsafe.Pointer(path)),
+		uintptr(unsafe.Pointer(&argv[0])),
+		uintptr(unsafe.Pointer(&envv[0])))
+	exitLimitsHelper(fmt.Errorf("Couldn't execute '%s'. %s", os.Args[1], errno))
+}
+
+func exitLimitsHelper(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(127)
+}
+
+// Validate checks whether the limits are valid.
+func (limits *Limits) Validate() error {
+	if limits.Memory < 0 {
+		return errors.New("Memory limit must be >= 0")
+	}
+	if limits.CPUShares < 0 || limits.CPUShares > 10000 {
+		return errors.New("Cpu shares must be in range [0, 10000]")
+	}
+	if limits.CPUQuota < 0 {
+		return errors.New("Cpu quota must be >= 0")
+	}
+	if limits.MaxProcesses < 0 {
+		return errors.New("Max processes limit must be >= 0")
+	}
+	if limits.MaxOpenFiles < 0 {
+		return errors.New("Max open files limit must be >= 0")
+	}
+	return nil
+}
+
+// Makes the command start the limits helper instead of its program.
+// The helper is the same native process as the command, it waits until the process is moved
+// to its cgroup, sets its rlimits and executes the program, so nothing the program runs escapes the limits.
+// Returns the function which must be called once the command is started or failed to start,
+// it moves the started process to its cgroup and lets the helper execute the program.
+// If the cgroup can't be set up the function kills the helper and returns an error.
+func limitCommand(cmd *exec.Cmd, limits *Limits) (func() error, error) {
+	if limits == nil || *limits == (Limits{}) {
+		return func() error { return nil }, nil
+	}
+	if !limitsHelperEnabled {
+		return nil, errors.New("Limits are not supported as the limits helper is not enabled")
+	}
+	_, cgroupsErr := cgroupsDir()
+	if (limits.CPUShares > 0 || limits.CPUQuota > 0) && cgroupsErr != nil {
+		return nil, fmt.Errorf("Cpu limits can't be applied without cgroups. %s", cgroupsErr)
+	}
+	byCgroup := cgroupsErr == nil &&
+		(limits.Memory > 0 || limits.CPUShares > 0 || limits.CPUQuota > 0 || limits.MaxProcesses > 0)
+
+	self, err := os.Readlink("/proc/self/exe")
+	if err != nil {
+		return nil, err
+	}
+	// the program path is resolved by the agent, as the process environment may lack PATH
+	program, err := exec.LookPath(cmd.Path)
+	if err != nil {
+		return nil, err
+	}
+	gate, release, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, limitsHelperEnv+"="+formatRlimits(limits, byCgroup))
+	cmd.ExtraFiles = []*os.File{gate}
+	cmd.Args = append([]string{self, program}, cmd.Args...)
+	cmd.Path = self
+	return func() error {
+		closeFile(gate)
+		defer closeFile(release)
+		if cmd.Process == nil || !byCgroup {
+			return nil
+		}
+		// the helper is killed before it executes the program
+		if err := limitByCgroup(cmd.Process.Pid, limits); err != nil {
+			cmd.Process.Kill()
+			return err
+		}
+		return nil
+	}, nil
+}
+
+// Returns comma separated 'resource=value' rlimits the limits helper sets,
+// memory and processes are limited by rlimits only if they are not limited by cgroup.
+func formatRlimits(limits *Limits, byCgroup bool) string {
+	var rlimits []string
+	if !byCgroup && limits.Memory > 0 {
+		rlimits = append(rlimits, fmt.Sprintf("%d=%d", syscall.RLIMIT_AS, limits.Memory))
+	}
+	if !byCgroup && limits.MaxProcesses > 0 {
+		rlimits = append(rlimits, fmt.Sprintf("%d=%d", rlimitNproc, limits.MaxProcesses))
+	}
+	if limits.MaxOpenFiles > 0 {
+		rlimits = append(rlimits, fmt.Sprintf("%d=%d", syscall.RLIMIT_NOFILE, limits.MaxOpenFiles))
+	}
+	return strings.Join(rlimits, ",")
+}
+
+func parseRlimits(rlimits string) ([]int, []uint64, error) {
+	var resources []int
+	var values []uint64
+	for _, item := range strings.Split(rlimits, ",") {
+		if item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			return nil, nil, fmt.Errorf("Limit '%s' is not valid", item)
+		}
+		resource, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, nil, fmt.Errorf("Limit '%s' is not valid", item)
+		}
+		value, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Limit '%s' is not valid", item)
+		}
+		resources = append(resources, resource)
+		values = append(values, value)
+	}
+	return resources, values, nil
+}
+
+// Releases the cgroup of the exited native process.
+// Returns true if any process of the cgroup was killed by OOM killer.
+func releaseLimits(nativePid int, limits *Limits) bool {
+	if limits == nil {
+		return false
+	}
+	if _, err := cgroupsDir(); err != nil {
+		return false
+	}
+	dir := cgroupOf(nativePid)
+	if _, err := os.Stat(dir); err != nil {
+		return false
+	}
+	oomKilled := readCgroupCounter(dir+"/memory.events", "oom_kill") > 0
+	if err := os.Remove(dir); err != nil {
+		log.Printf("Couldn't remove cgroup '%s'. %s", dir, err)
+	}
+	return oomKilled
+}
+
+// Creates a child cgroup for the native process and moves the process into it,
+// returns an error if any of the limits couldn't be set.
+func limitByCgroup(nativePid int, limits *Limits) error {
+	dir := cgroupOf(nativePid)
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return fmt.Errorf("Couldn't create cgroup '%s'. %s", dir, err)
+	}
+	settings := make(map[string]string)
+	if limits.Memory > 0 {
+		settings["memory.max"] = strconv.FormatInt(limits.Memory, 10)
+	}
+	if limits.CPUShares > 0 {
+		settings["cpu.weight"] = strconv.Itoa(limits.CPUShares)
+	}
+	if limits.CPUQuota > 0 {
+		settings["cpu.max"] = fmt.Sprintf("%d %d", limits.CPUQuota*cpuPeriod/1000, cpuPeriod)
+	}
+	if limits.MaxProcesses > 0 {
+		settings["pids.max"] = strconv.Itoa(limits.MaxProcesses)
+	}
+	settings["cgroup.procs"] = strconv.Itoa(nativePid)
+	for _, name := range []string{"memory.max", "cpu.weight", "cpu.max", "pids.max", "cgroup.procs"} {
+		value, ok := settings[name]
+		if !ok {
+			continue
+		}
+		if err := ioutil.WriteFile(dir+"/"+name, []byte(value), 0644); err != nil {
+			os.Remove(dir)
+			return fmt.Errorf("Couldn't set '%s' of cgroup '%s'. %s", name, dir, err)
+		}
+	}
+	return nil
+}
+
+func cgroupOf(nativePid int) string {
+	return fmt.Sprintf("%s/exec-agent-%d", cgroupsParent, nativePid)
+}
+
+// Returns the cgroup v2 directory which delegates memory, cpu and pids controllers
+// to the process cgroups or an error describing why cgroups are not delegated,
+// the result is computed once.
+func cgroupsDir() (string, error) {
+	cgroupsParentOnce.Do(func() {
+		cgroupsParent, cgroupsErr = delegatingCgroup()
+		if cgroupsErr != nil {
+			log.Printf("Limits are not applied by cgroups. %s", cgroupsErr)
+		}
+	})
+	return cgroupsParent, cgroupsErr
+}
+
+// Enables controllers for the children of the configured cgroup or the cgroup of the agent.
+// Processes are never moved between cgroups, so a non root cgroup which has processes
+// of its own, like the cgroup of the agent usually has, can't delegate controllers.
+func delegatingCgroup() (string, error) {
+	dir := limitsCgroup
+	if dir == "" {
+		mount := cgroup2Mount()
+		if mount == "" {
+			return "", errors.New("Cgroup v2 hierarchy is not mounted")
+		}
+		dir = mount + ownCgroup()
+	}
+	control := dir + "/cgroup.subtree_control"
+	if err := syscall.Access(control, accessWriteOK); err != nil {
+		return "", fmt.Errorf("Cgroup '%s' is not writable. %s", dir, err)
+	}
+	content, err := ioutil.ReadFile(dir + "/cgroup.controllers")
+	if err != nil {
+		return "", err
+	}
+	available := make(map[string]bool)
+	for _, controller := range strings.Fields(string(content)) {
+		available[controller] = true
+	}
+	for _, controller := range []string{"memory", "cpu", "pids"} {
+		if !available[controller] {
+			return "", fmt.Errorf("Controller '%s' is not available in cgroup '%s'", controller, dir)
+		}
+		if err := ioutil.WriteFile(control, []byte("+"+controller), 0644); err != nil {
+			return "", fmt.Errorf("Cgroup '%s' can't delegate controller '%s', "+
+				"the cgroup must be the root or have no processes of its own. %s", dir, controller, err)
+		}
+	}
+	return dir, nil
+}
+
+// Returns the mount point of cgroup v2 hierarchy or empty string if it's not mounted.
+func cgroup2Mount() string {
+	file, err := os.Open(procDir + "/self/mountinfo")
+	if err != nil {
+		return ""
+	}
+	defer closeFile(file)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// fields after ' - ' separator are fstype, source and super options
+		parts := strings.SplitN(scanner.Text(), " - ", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[1], "cgroup2 ") {
+			continue
+		}
+		if fields := strings.Fields(parts[0]); len(fields) > 4 {
+			return fields[4]
+		}
+	}
+	return ""
+}
+
+// Returns the cgroup v2 path of the agent relative to the hierarchy root.
+func ownCgroup() string {
+	content, err := ioutil.ReadFile(procDir + "/self/cgroup")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.HasPrefix(line, "0::") {
+			return strings.TrimSuffix(line[len("0::"):], "/")
+		}
+	}
+	return ""
+}
+
+// Reads the value of the key from flat keyed cgroup file like memory.events.
+func readCgroupCounter(filename string, key string) int {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == key {
+			value, _ := strconv.Atoi(fields[1])
+			return value
+		}
+	}
+	return 0
+}
+
+// Sets both soft and hard limits of the resource for the native process, 0 means the current process.
+// The raw syscall is used, as the limits helper may be unable to allocate memory.
+func setRlimit(nativePid int, resource int, value uint64) syscall.Errno {
+	rlimit := syscall.Rlimit{Cur: value, Max: value}
+	_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64,
+		uintptr(nativePid),
+		uintptr(resource),
+		uintptr(unsafe.Pointer(&rlimit)),
+		0, 0, 0)
+	return errno
+}
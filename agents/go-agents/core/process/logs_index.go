@@ -0,0 +1,207 @@
+//
+// Copyright (c) 2012-2017 Codenvy, S.A.
+// All rights reserved. This program and the accompanying materials
+// are made available under the terms of the Eclipse Public License v1.0
+// which accompanies this distribution, and is available at
+// http://www.eclipse.org/legal/epl-v10.html
+//
+// Contributors:
+//   Codenvy, S.A. - initial API and implementation
+//
+
+package process
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	// LogsIndexSuffix is appended to the name of the logs file to get the name of its index file.
+	LogsIndexSuffix = ".idx"
+
+	// the min number of bytes between offsets of two sequential index entries
+	indexInterval = 64 * 1024
+)
+
+// logsIndexEntry points to the first line of the bucket of log lines,
+// the lines of the bucket are in range [entry, next entry).
+type logsIndexEntry struct {
+	// The time of the first line in the bucket.
+	Time time.Time `json:"time"`
+
+	// The number of the first line in the bucket, starting from 0.
+	Line int64 `json:"line"`
+
+	// The offset of the first line in the bucket.
+	Offset int64 `json:"offset"`
+}
+
+// logsIndex is the sidecar of the logs file, it is kept in the file with the same name
+// as the logs file plus LogsIndexSuffix, one json entry per line.
+// As log lines are written in time order, index entries are sorted by both time and offset.
+type logsIndex []logsIndexEntry
+
+func indexFilename(filename string) string {
+	return filename + LogsIndexSuffix
+}
+
+// Loads the index of the logs file, the index is rebuilt if it's missing or broken.
+func loadIndex(filename string, logsSize int64) (logsIndex, error) {
+	index, err := readIndex(indexFilename(filename))
+	if err == nil && index.valid(logsSize) {
+		return index, nil
+	}
+	return rebuildIndex(filename)
+}
+
+func readIndex(filename string) (logsIndex, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFile(file)
+
+	index := logsIndex{}
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	for {
+		entry := logsIndexEntry{}
+		if err := decoder.Decode(&entry); err != nil {
+			if err == io.EOF {
+				return index, nil
+			}
+			return nil, err
+		}
+		index = append(index, entry)
+	}
+}
+
+// Scans the logs file, builds its index and replaces the existing index file.
+// Malformed lines are skipped, the bucket which starts with such lines
+// gets the time of its first well formed line.
+// The index is returned even if it couldn't be written.
+func rebuildIndex(filename string) (logsIndex, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFile(file)
+
+	index := logsIndex{}
+	reader := bufio.NewReader(file)
+	var offset, line int64
+	indexedOffset := int64(-indexInterval)
+	var pending *logsIndexEntry
+	for {
+		content, err := reader.ReadBytes('\n')
+		if err != nil {
+			// the last line without the new line character is not flushed completely
+			break
+		}
+		if pending == nil && offset-indexedOffset >= indexInterval {
+			pending = &logsIndexEntry{Line: line, Offset: offset}
+			indexedOffset = offset
+		}
+		if pending != nil {
+			message := &LogMessage{}
+			if err := json.Unmarshal(content, message); err != nil {
+				log.Printf("Skipping malformed line %d of logs file '%s' while indexing. %s", line+1, filename, err)
+			} else {
+				pending.Time = message.Time
+				index = append(index, *pending)
+				pending = nil
+			}
+		}
+		offset += int64(len(content))
+		line++
+	}
+
+	if err := writeIndex(filename, index); err != nil {
+		log.Printf("Couldn't write index of logs file '%s'. %s", filename, err)
+	}
+	return index, nil
+}
+
+// Writes the index into a temporary file and then replaces the existing index with it,
+// so readers never see the partially written index.
+func writeIndex(filename string, index logsIndex) error {
+	buffer := &bytes.Buffer{}
+	encoder := json.NewEncoder(buffer)
+	for _, entry := range index {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := buffer.WriteTo(tmp); err != nil {
+		closeFile(tmp)
+		os.Remove(tmp.Name())
+		return err
+	}
+	closeFile(tmp)
+	return os.Rename(tmp.Name(), indexFilename(filename))
+}
+
+// Appends the entry to the index of the logs file.
+func appendIndexEntry(filename string, entry logsIndexEntry) error {
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(indexFilename(filename), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+	defer closeFile(file)
+	_, err = file.Write(append(content, '\n'))
+	return err
+}
+
+// Checks that the index starts from the beginning of the logs file
+// and its entries point inside the logs file in the increasing order.
+// The index may miss the entries of the latest logs, it only makes reading of them slower.
+func (index logsIndex) valid(logsSize int64) bool {
+	if logsSize == 0 {
+		return true
+	}
+	if len(index) == 0 || index[0].Offset != 0 {
+		return false
+	}
+	for i := 1; i < len(index); i++ {
+		if index[i].Offset <= index[i-1].Offset || index[i].Time.Before(index[i-1].Time) {
+			return false
+		}
+	}
+	return index[len(index)-1].Offset < logsSize
+}
+
+// Returns the offset of the bucket which may contain the first log at or after the given time.
+func (index logsIndex) offsetFrom(from time.Time) int64 {
+	i := sort.Search(len(index), func(i int) bool { return !index[i].Time.Before(from) })
+	if i == 0 {
+		return 0
+	}
+	// the previous bucket starts before 'from' but may end with the logs at 'from'
+	return index[i-1].Offset
+}
+
+// Returns the offset of the bucket all the logs of which appeared after the given time,
+// or -1 if there is no such bucket.
+func (index logsIndex) offsetAfter(till time.Time) int64 {
+	i := sort.Search(len(index), func(i int) bool { return index[i].Time.After(till) })
+	if i == len(index) {
+		return -1
+	}
+	return index[i].Offset
+}
@@ -0,0 +1,122 @@
+//
+// Copyright (c) 2012-2017 Codenvy, S.A.
+// All rights reserved. This program and the accompanying materials
+// are made available under the terms of the Eclipse Public License v1.0
+// which accompanies this distribution, and is available at
+// http://www.eclipse.org/legal/epl-v10.html
+//
+// Contributors:
+//   Codenvy, S.A. - initial API and implementation
+//
+
+package process_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eclipse/che/agents/go-agents/core/process"
+)
+
+func TestLogsAreReadUsingIndex(t *testing.T) {
+	filename := os.TempDir() + string(os.PathSeparator) + randomName(10)
+	defer removeFile(filename)
+
+	now := writeIndexedLogs(t, filename, 5000)
+	if _, err := os.Stat(filename + process.LogsIndexSuffix); err != nil {
+		t.Fatalf("Expected logs index to be written, but it wasn't. %s", err)
+	}
+
+	checkIndexedLogsRead(t, filename, now)
+}
+
+func TestLogsIndexIsRebuiltIfMissing(t *testing.T) {
+	filename := os.TempDir() + string(os.PathSeparator) + randomName(10)
+	defer removeFile(filename)
+
+	now := writeIndexedLogs(t, filename, 5000)
+	if err := os.Remove(filename + process.LogsIndexSuffix); err != nil {
+		t.Fatal(err)
+	}
+
+	checkIndexedLogsRead(t, filename, now)
+	if _, err := os.Stat(filename + process.LogsIndexSuffix); err != nil {
+		t.Fatalf("Expected logs index to be rebuilt, but it wasn't. %s", err)
+	}
+	checkIndexedLogsRead(t, filename, now)
+}
+
+func TestLogsIndexIsRebuiltSkippingMalformedLines(t *testing.T) {
+	filename := os.TempDir() + string(os.PathSeparator) + randomName(10)
+	defer removeFile(filename)
+
+	now := writeIndexedLogs(t, filename, 5000)
+	if err := os.Remove(filename + process.LogsIndexSuffix); err != nil {
+		t.Fatal(err)
+	}
+	// break the first line, so the first bucket starts with the malformed line
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filename, append([]byte("{malformed\n"), content...), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	// the malformed line is skipped only if the reading is started using the index
+	checkIndexedLogsRead(t, filename, now)
+	if _, err := os.Stat(filename + process.LogsIndexSuffix); err != nil {
+		t.Fatalf("Expected logs index to be rebuilt, but it wasn't. %s", err)
+	}
+}
+
+// Writes n lines, the i-th line appears at now + i seconds.
+func writeIndexedLogs(t *testing.T, filename string, n int) time.Time {
+	fl, err := process.NewLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	text := strings.Repeat("x", 100)
+	for i := 0; i < n; i++ {
+		fl.OnStdout(fmt.Sprintf("%d %s", i, text), now.Add(time.Second*time.Duration(i)))
+	}
+	fl.Close()
+	return now
+}
+
+func checkIndexedLogsRead(t *testing.T, filename string, now time.Time) {
+	logs, err :=
+		process.NewLogsReader(filename).
+			From(now.Add(time.Second * 3000)).
+			Till(now.Add(time.Second * 3009)).
+			ReadLogs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkIndexedLogs(t, logs, 3000, 10)
+
+	logs, err =
+		process.NewLogsReader(filename).
+			Till(now.Add(time.Second * 1000)).
+			ReadTail(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkIndexedLogs(t, logs, 996, 5)
+}
+
+func checkIndexedLogs(t *testing.T, logs []*process.LogMessage, first int, n int) {
+	if len(logs) != n {
+		t.Fatalf("Expected %d logs but found %d", n, len(logs))
+	}
+	for i, message := range logs {
+		if !strings.HasPrefix(message.Text, fmt.Sprintf("%d ", first+i)) {
+			t.Fatalf("Expected log %d to start with '%d ' but it is '%s'", i, first+i, message.Text)
+		}
+	}
+}
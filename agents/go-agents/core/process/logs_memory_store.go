@@ -0,0 +1,172 @@
+//
+// Copyright (c) 2012-2017 Codenvy, S.A.
+// All rights reserved. This program and the accompanying materials
+// are made available under the terms of the Eclipse Public License v1.0
+// which accompanies this distribution, and is available at
+// http://www.eclipse.org/legal/epl-v10.html
+//
+// Contributors:
+//   Codenvy, S.A. - initial API and implementation
+//
+
+package process
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMemoryLogsCapacity is the number of the latest logs
+	// kept in memory per process unless another one is configured.
+	DefaultMemoryLogsCapacity = 10000
+)
+
+// MemoryLogStore keeps the latest logs of each process in a ring buffer,
+// when the buffer is full the earliest logs are overwritten by the new ones.
+type MemoryLogStore struct {
+	sync.RWMutex
+	capacity int
+	rings    map[uint64]*logsRing
+}
+
+type logsRing struct {
+	messages []*LogMessage
+
+	// the index of the earliest message
+	start int
+
+	// the number of overwritten messages, so lines are numbered from the very first message
+	dropped int
+
+	// the size of the texts of the kept messages
+	size int64
+}
+
+// NewMemoryLogStore creates a store which keeps at most capacity latest logs per process,
+// non positive capacity means DefaultMemoryLogsCapacity.
+func NewMemoryLogStore(capacity int) *MemoryLogStore {
+	if capacity <= 0 {
+		capacity = DefaultMemoryLogsCapacity
+	}
+	return &MemoryLogStore{
+		capacity: capacity,
+		rings:    make(map[uint64]*logsRing),
+	}
+}
+
+// Create creates an empty ring buffer for the process.
+func (store *MemoryLogStore) Create(pid uint64) error {
+	store.Lock()
+	store.rings[pid] = &logsRing{}
+	store.Unlock()
+	return nil
+}
+
+// Append puts the message into the ring buffer, overwriting the earliest message if it's full.
+func (store *MemoryLogStore) Append(pid uint64, message *LogMessage) error {
+	store.Lock()
+	defer store.Unlock()
+	ring, ok := store.rings[pid]
+	if !ok {
+		return missingLogs(pid)
+	}
+	if len(ring.messages) < store.capacity {
+		ring.messages = append(ring.messages, message)
+	} else {
+		ring.size -= int64(len(ring.messages[ring.start].Text))
+		ring.messages[ring.start] = message
+		ring.start = (ring.start + 1) % len(ring.messages)
+		ring.dropped++
+	}
+	ring.size += int64(len(message.Text))
+	return nil
+}
+
+// Flush does nothing as appended messages are immediately available.
+func (store *MemoryLogStore) Flush(pid uint64) error {
+	return nil
+}
+
+// Close does nothing as the logs are kept until they are deleted.
+func (store *MemoryLogStore) Close(pid uint64) error {
+	return nil
+}
+
+// Read returns the kept logs between [from, till] inclusive.
+func (store *MemoryLogStore) Read(pid uint64, from time.Time, till time.Time) ([]*LogMessage, error) {
+	logs := []*LogMessage{}
+	err := store.Scan(pid, from, till, func(line int, message *LogMessage) bool {
+		logs = append(logs, message)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// ReadTail returns at most n latest kept logs between [from, till] inclusive.
+func (store *MemoryLogStore) ReadTail(pid uint64, from time.Time, till time.Time, n int) ([]*LogMessage, error) {
+	logs, err := store.Read(pid, from, till)
+	if err != nil {
+		return nil, err
+	}
+	if n >= 0 && len(logs) > n {
+		logs = logs[len(logs)-n:]
+	}
+	return logs, nil
+}
+
+// Scan calls the function for each kept log between [from, till] inclusive,
+// line numbers take into account the overwritten logs.
+func (store *MemoryLogStore) Scan(pid uint64, from time.Time, till time.Time, f func(line int, message *LogMessage) bool) error {
+	store.RLock()
+	ring, ok := store.rings[pid]
+	if !ok {
+		store.RUnlock()
+		return missingLogs(pid)
+	}
+	// copy the messages, so the function may block without blocking the writers
+	messages := make([]*LogMessage, 0, len(ring.messages))
+	messages = append(messages, ring.messages[ring.start:]...)
+	messages = append(messages, ring.messages[:ring.start]...)
+	dropped := ring.dropped
+	store.RUnlock()
+
+	for i, message := range messages {
+		if message.Time.Before(from) {
+			continue
+		}
+		if message.Time.After(till) || !f(dropped+i+1, message) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Delete drops the ring buffer of the process.
+func (store *MemoryLogStore) Delete(pid uint64) error {
+	store.Lock()
+	delete(store.rings, pid)
+	store.Unlock()
+	return nil
+}
+
+// Size returns the size of the texts of the kept logs.
+func (store *MemoryLogStore) Size(pid uint64) int64 {
+	store.RLock()
+	defer store.RUnlock()
+	if ring, ok := store.rings[pid]; ok {
+		return ring.size
+	}
+	return 0
+}
+
+// Returns an error indicating that the store doesn't keep logs of the process,
+// the error satisfies os.IsNotExist the same as the error of missing logs file.
+func missingLogs(pid uint64) error {
+	return &os.PathError{Op: "read", Path: fmt.Sprintf("logs of process '%d'", pid), Err: os.ErrNotExist}
+}
@@ -0,0 +1,73 @@
+//
+// Copyright (c) 2012-2017 Codenvy, S.A.
+// All rights reserved. This program and the accompanying materials
+// are made available under the terms of the Eclipse Public License v1.0
+// which accompanies this distribution, and is available at
+// http://www.eclipse.org/legal/epl-v10.html
+//
+// Contributors:
+//   Codenvy, S.A. - initial API and implementation
+//
+
+package process
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMemoryLogStoreKeepsLatestLogs(t *testing.T) {
+	store := NewMemoryLogStore(3)
+	if err := store.Create(1); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for i := 1; i <= 5; i++ {
+		store.Append(1, &LogMessage{Kind: StdoutKind, Time: start.Add(time.Duration(i) * time.Second), Text: strconv.Itoa(i)})
+	}
+
+	logs, err := store.Read(1, time.Time{}, start.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if texts := logsTexts(logs); texts != "3,4,5" {
+		t.Fatalf("Expected the latest logs '3,4,5' to be kept but got '%s'", texts)
+	}
+	if size := store.Size(1); size != 3 {
+		t.Fatalf("Expected the size to be 3 but got %d", size)
+	}
+
+	tail, err := store.ReadTail(1, time.Time{}, start.Add(4*time.Second), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if texts := logsTexts(tail); texts != "4" {
+		t.Fatalf("Expected the tail to be '4' but got '%s'", texts)
+	}
+
+	lines := []int{}
+	store.Scan(1, start.Add(4*time.Second), start.Add(time.Minute), func(line int, message *LogMessage) bool {
+		lines = append(lines, line)
+		return true
+	})
+	if len(lines) != 2 || lines[0] != 4 || lines[1] != 5 {
+		t.Fatalf("Expected lines to be numbered including overwritten logs but got %v", lines)
+	}
+
+	store.Delete(1)
+	if _, err := store.Read(1, time.Time{}, time.Now()); err == nil {
+		t.Fatal("Expected an error when reading deleted logs")
+	}
+}
+
+func logsTexts(logs []*LogMessage) string {
+	texts := ""
+	for i, message := range logs {
+		if i != 0 {
+			texts += ","
+		}
+		texts += message.Text
+	}
+	return texts
+}
@@ -13,12 +13,19 @@ package process
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
 	"io"
+	"log"
 	"os"
 	"time"
 )
 
+const (
+	// the size of the chunk logs file is read backwards by
+	tailChunkSize = 8192
+)
+
 // LogsReader allows to read logs from file written by DefaultFileLogger
 type LogsReader struct {
 	filename string
@@ -45,17 +52,223 @@ func (lr *LogsReader) Till(time time.Time) *LogsReader {
 }
 
 // ReadLogs reads logs between [from, till] inclusive.
+// Rotated segments of the logs file are read before the file itself.
 // Returns an error if logs file is missing, or
 // decoding of file content failed.
 // If no logs matched time frame, an empty slice will be returned.
 func (lr *LogsReader) ReadLogs() ([]*LogMessage, error) {
+	from, till := lr.bounds()
+	logs := []*LogMessage{}
+
+	// Read rotated segments, skipping those which end before 'from'
+	segments := listSegments(lr.filename)
+	for i, segment := range segments {
+		if !from.IsZero() {
+			next := lr.filename
+			if i+1 < len(segments) {
+				next = segments[i+1]
+			}
+			if nextTime, ok := firstLogTime(next); ok && nextTime.Before(from) {
+				continue
+			}
+		}
+		reader, err := openSegment(segment)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// the segment was removed or compressed after it was listed
+				continue
+			}
+			return nil, err
+		}
+		done, err := decodeLogs(reader, from, till, &logs)
+		reader.Close()
+		if err != nil || done {
+			return logs, err
+		}
+	}
+
 	// Open logs file for reading logs
 	logsFile, err := os.Open(lr.filename)
 	if err != nil {
+		if os.IsNotExist(err) && len(segments) != 0 {
+			// the file is being rotated
+			return logs, nil
+		}
 		return nil, err
 	}
 	defer closeFile(logsFile)
 
+	// Seek to the bucket of the first log, the index is not needed if logs are read from the start
+	if !from.IsZero() {
+		if offset := lr.offsetFrom(logsFile, from); offset > 0 {
+			if _, err := logsFile.Seek(offset, io.SeekStart); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Read logs
+	if _, err := decodeLogs(bufio.NewReader(logsFile), from, till, &logs); err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// ReadTail reads at most n latest logs between [from, till] inclusive.
+// The file is read backwards from its end, so only the needed logs are decoded,
+// rotated segments are read only if the file doesn't contain enough logs.
+// Returns an error if logs file is missing, or decoding of file content failed.
+func (lr *LogsReader) ReadTail(n int) ([]*LogMessage, error) {
+	from, till := lr.bounds()
+	segments := listSegments(lr.filename)
+
+	// logs are collected from the latest to the earliest
+	logs := []*LogMessage{}
+	reachedFrom := false
+	logsFile, err := os.Open(lr.filename)
+	if err != nil {
+		if !os.IsNotExist(err) || len(segments) == 0 {
+			return nil, err
+		}
+		// the file is being rotated
+	} else {
+		defer closeFile(logsFile)
+		if reachedFrom, err = lr.readFileTail(logsFile, from, till, n, &logs); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := len(segments) - 1; i >= 0 && len(logs) < n && !reachedFrom; i-- {
+		firstTime, ok := firstLogTime(segments[i])
+		if !ok || firstTime.After(till) {
+			continue
+		}
+		reachedFrom = firstTime.Before(from)
+
+		reader, err := openSegment(segments[i])
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		segmentLogs := []*LogMessage{}
+		_, err = decodeLogs(reader, from, till, &segmentLogs)
+		reader.Close()
+		if err != nil {
+			return nil, err
+		}
+		for j := len(segmentLogs) - 1; j >= 0 && len(logs) < n; j-- {
+			logs = append(logs, segmentLogs[j])
+		}
+	}
+
+	for i, j := 0, len(logs)-1; i < j; i, j = i+1, j-1 {
+		logs[i], logs[j] = logs[j], logs[i]
+	}
+	return logs, nil
+}
+
+// Scan calls the function for each log between [from, till] inclusive along with the number
+// of its line in the logs starting from 1, rotated segments are scanned before the file.
+// Logs are decoded one by one, so they are not kept in memory.
+// Scanning stops when the function returns false.
+// Returns an error if logs file is missing, or decoding of file content failed.
+func (lr *LogsReader) Scan(f func(line int, message *LogMessage) bool) error {
+	from, till := lr.bounds()
+	segments := listSegments(lr.filename)
+	line := 0
+	for _, name := range append(segments, lr.filename) {
+		reader, err := openSegment(name)
+		if err != nil {
+			if os.IsNotExist(err) && len(segments) != 0 {
+				// the segment is removed or the file is being rotated
+				continue
+			}
+			return err
+		}
+		decoder := json.NewDecoder(bufio.NewReader(reader))
+		for {
+			message := &LogMessage{}
+			if err := decoder.Decode(message); err != nil {
+				reader.Close()
+				if err == io.EOF {
+					break
+				}
+				return err
+			}
+			line++
+			if message.Time.Before(from) {
+				continue
+			}
+			if message.Time.After(till) || !f(line, message) {
+				reader.Close()
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// Reads the file backwards appending at most n logs to the given ones.
+// Returns true if the log before 'from' is reached, so the earlier logs are not needed.
+func (lr *LogsReader) readFileTail(logsFile *os.File, from, till time.Time, n int, logs *[]*LogMessage) (bool, error) {
+	info, err := logsFile.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	// the logs after 'till' are not read if they are indexed
+	offset := info.Size()
+	if till.Before(info.ModTime()) {
+		if index, err := loadIndex(lr.filename, info.Size()); err == nil {
+			if after := index.offsetAfter(till); after >= 0 {
+				offset = after
+			}
+		}
+	}
+
+	var partial []byte
+	for offset > 0 && len(*logs) < n {
+		size := int64(tailChunkSize)
+		if offset < size {
+			size = offset
+		}
+		offset -= size
+		chunk := make([]byte, size, size+int64(len(partial)))
+		if _, err := logsFile.ReadAt(chunk, offset); err != nil {
+			return false, err
+		}
+		lines := bytes.Split(append(chunk, partial...), []byte{'\n'})
+
+		// the first line may be the end of the line from the previous chunk
+		first := 0
+		if offset > 0 {
+			partial = lines[0]
+			first = 1
+		}
+		for i := len(lines) - 1; i >= first && len(*logs) < n; i-- {
+			if len(bytes.TrimSpace(lines[i])) == 0 {
+				continue
+			}
+			message := &LogMessage{}
+			if err := json.Unmarshal(lines[i], message); err != nil {
+				return false, err
+			}
+			if message.Time.After(till) {
+				continue
+			}
+			if message.Time.Before(from) {
+				return true, nil
+			}
+			*logs = append(*logs, message)
+		}
+	}
+	return false, nil
+}
+
+// Returns the time range logs are read in.
+func (lr *LogsReader) bounds() (time.Time, time.Time) {
 	from := time.Time{}
 	if lr.readFrom != nil {
 		from = *lr.readFrom
@@ -64,26 +277,42 @@ func (lr *LogsReader) ReadLogs() ([]*LogMessage, error) {
 	if lr.readTill != nil {
 		till = *lr.readTill
 	}
+	return from, till
+}
 
-	// Read logs
-	logs := []*LogMessage{}
-	decoder := json.NewDecoder(bufio.NewReader(logsFile))
+// Decodes logs between [from, till] appending them to the given ones.
+// Returns true if the log after 'till' is reached, so the later logs are not needed.
+func decodeLogs(reader io.Reader, from, till time.Time, logs *[]*LogMessage) (bool, error) {
+	decoder := json.NewDecoder(reader)
 	for {
 		message := &LogMessage{}
-		err = decoder.Decode(message)
-		if err != nil {
+		if err := decoder.Decode(message); err != nil {
 			if err == io.EOF {
-				break
+				return false, nil
 			}
-			return nil, err
+			return false, err
 		}
 		if message.Time.Before(from) {
 			continue
 		}
 		if message.Time.After(till) {
-			break
+			return true, nil
 		}
-		logs = append(logs, message)
+		*logs = append(*logs, message)
 	}
-	return logs, nil
+}
+
+// Returns the offset to start reading logs from the given time,
+// if the index can't be loaded logs are read from the start.
+func (lr *LogsReader) offsetFrom(logsFile *os.File, from time.Time) int64 {
+	info, err := logsFile.Stat()
+	if err != nil {
+		return 0
+	}
+	index, err := loadIndex(lr.filename, info.Size())
+	if err != nil {
+		log.Printf("Couldn't load index of logs file '%s'. %s", lr.filename, err)
+		return 0
+	}
+	return index.offsetFrom(from)
 }
@@ -12,6 +12,7 @@
 package process_test
 
 import (
+	"fmt"
 	"os"
 	"testing"
 	"time"
@@ -58,6 +59,71 @@ func TestReadLogs(t *testing.T) {
 	}
 }
 
+func TestReadTail(t *testing.T) {
+	filename := os.TempDir() + string(os.PathSeparator) + randomName(10)
+	defer removeFile(filename)
+
+	fl, err := process.NewLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Write enough logs to be read by several chunks
+	now := time.Now()
+	for i := 1; i <= 1000; i++ {
+		fl.OnStdout(fmt.Sprintf("line%d", i), now.Add(time.Second*time.Duration(i)))
+	}
+	fl.Close()
+
+	// Read 3 latest logs till 900
+	logs, err :=
+		process.NewLogsReader(filename).
+			Till(now.Add(time.Second * 900)).
+			ReadTail(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []process.LogMessage{
+		{Kind: process.StdoutKind, Time: now.Add(time.Second * 898), Text: "line898"},
+		{Kind: process.StdoutKind, Time: now.Add(time.Second * 899), Text: "line899"},
+		{Kind: process.StdoutKind, Time: now.Add(time.Second * 900), Text: "line900"},
+	}
+	if len(logs) != len(expected) {
+		t.Fatalf("Expected %d logs but found %d", len(expected), len(logs))
+	}
+	for i := 0; i < len(logs); i++ {
+		failIfDifferent(t, *logs[i], expected[i])
+	}
+
+	// Read logs [995, 1000] while asking for more
+	logs, err =
+		process.NewLogsReader(filename).
+			From(now.Add(time.Second * 995)).
+			Till(now.Add(time.Second * 1000)).
+			ReadTail(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 6 {
+		t.Fatalf("Expected 6 logs but found %d", len(logs))
+	}
+	failIfDifferent(t, *logs[0], process.LogMessage{Kind: process.StdoutKind, Time: now.Add(time.Second * 995), Text: "line995"})
+
+	// Read all the logs
+	logs, err = process.NewLogsReader(filename).Till(now.Add(time.Hour)).ReadTail(5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1000 {
+		t.Fatalf("Expected 1000 logs but found %d", len(logs))
+	}
+	for i := 0; i < len(logs); i++ {
+		if logs[i].Text != fmt.Sprintf("line%d", i+1) {
+			t.Fatalf("Expected 'line%d' but found '%s'", i+1, logs[i].Text)
+		}
+	}
+}
+
 func failIfDifferent(t *testing.T, expected process.LogMessage, actual process.LogMessage) {
 	if expected.Kind != actual.Kind || expected.Text != actual.Text || expected.Time.Unix() != actual.Time.Unix() {
 		t.Fatalf("Expected: '%v' Found '%v'", expected, actual)
@@ -0,0 +1,319 @@
+//
+// Copyright (c) 2012-2017 Codenvy, S.A.
+// All rights reserved. This program and the accompanying materials
+// are made available under the terms of the Eclipse Public License v1.0
+// which accompanies this distribution, and is available at
+// http://www.eclipse.org/legal/epl-v10.html
+//
+// Contributors:
+//   Codenvy, S.A. - initial API and implementation
+//
+
+package process
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// CompressedSegmentSuffix is appended to the name of the rotated logs segment once it's compressed.
+	CompressedSegmentSuffix = ".gz"
+
+	// the delay before the next attempt to rotate the logs file after rotation failed
+	rotationRetryDelay = time.Minute
+)
+
+var (
+	// the size of the logs file in bytes after which it is rotated, 0 disables rotation by size
+	logsMaxSize int64
+
+	// the age of the logs file after which it is rotated, 0 disables rotation by age
+	logsMaxAge time.Duration
+
+	// the max number of rotated segments kept per process, 0 means all the segments are kept
+	logsMaxSegments int
+
+	// serializes replacing of the segment with its compressed file and removing of the segment
+	segmentsLocks = &segmentsLocksMap{items: make(map[string]*segmentLock)}
+)
+
+// Lockable map of the locks of the segments, the lock of the segment
+// exists while it is locked or waited for.
+type segmentsLocksMap struct {
+	sync.Mutex
+	items map[string]*segmentLock
+}
+
+type segmentLock struct {
+	sync.Mutex
+	refs int
+}
+
+// SetLogsRotation configures rotation of process logs files.
+// The logs file is rotated when it grows bigger than maxSize bytes or
+// when it's older than maxAge, zero values disable corresponding rotation.
+// Rotated segments are compressed by gzip, only maxSegments latest segments
+// are kept per process, 0 means that all of them are kept.
+func SetLogsRotation(maxSize int64, maxAge time.Duration, maxSegments int) {
+	logsMaxSize = maxSize
+	logsMaxAge = maxAge
+	logsMaxSegments = maxSegments
+}
+
+// Removes the logs file along with its index and all its rotated segments.
+func removeLogs(filename string) error {
+	if filename == "" {
+		return nil
+	}
+	for _, segment := range listSegments(filename) {
+		removeSegment(segment)
+	}
+	os.Remove(indexFilename(filename))
+	return os.Remove(filename)
+}
+
+// Returns the size in bytes of the logs file along with its index and all its rotated segments.
+func logsSize(filename string) int64 {
+	if filename == "" {
+		return 0
+	}
+	var size int64
+	for _, name := range append(listSegments(filename), filename, indexFilename(filename)) {
+		if info, err := os.Stat(name); err == nil {
+			size += info.Size()
+		}
+	}
+	return size
+}
+
+// Checks whether the logger needs to rotate the logs file, the logger must be locked.
+func (fl *FileLogger) rotationNeeded() bool {
+	if fl.offset == 0 || time.Now().Before(fl.rotationRetryTime) {
+		return false
+	}
+	return (logsMaxSize > 0 && fl.offset >= logsMaxSize) ||
+		(logsMaxAge > 0 && time.Since(fl.segmentTime) >= logsMaxAge)
+}
+
+// Renames the logs file to the next segment, so logs are written into the new file.
+// The segment is compressed asynchronously, the logger must be locked.
+// If rotation fails the logs are written into the same file and the rotation
+// is not retried until rotationRetryDelay elapses.
+func (fl *FileLogger) rotate() {
+	seq := fl.segments + 1
+	segment := segmentFilename(fl.filename, seq)
+
+	// index is not kept for segments as compressed files are not seekable
+	os.Remove(indexFilename(fl.filename))
+	if err := os.Rename(fl.filename, segment); err != nil {
+		log.Printf("Couldn't rotate logs file '%s'. %s \n", fl.filename, err.Error())
+		fl.rotationFailed()
+		return
+	}
+	file, err := os.Create(fl.filename)
+	if err != nil {
+		log.Printf("Couldn't create logs file '%s' after rotation. %s \n", fl.filename, err.Error())
+		// readers expect the logs file to exist, so the rotation is undone
+		if err := os.Rename(segment, fl.filename); err == nil {
+			fl.rotationFailed()
+			return
+		}
+		// the file is created by the next flush then
+		log.Printf("Couldn't restore logs file '%s' from segment '%s'. %s \n", fl.filename, segment, err.Error())
+	} else {
+		closeFile(file)
+	}
+
+	fl.segments = seq
+	fl.offset = 0
+	fl.lines = 0
+	fl.indexedOffset = -1
+	fl.indexBroken = false
+	fl.rotationRetryTime = time.Time{}
+
+	if logsMaxSegments > 0 && seq > logsMaxSegments {
+		removeSegment(segmentFilename(fl.filename, seq-logsMaxSegments))
+	}
+	go compressSegment(segment)
+}
+
+// Postpones the next rotation of the logs file which is kept as is,
+// its index is already removed so it's rebuilt by the next reader.
+func (fl *FileLogger) rotationFailed() {
+	fl.rotationRetryTime = time.Now().Add(rotationRetryDelay)
+	fl.breakIndex()
+}
+
+func segmentFilename(filename string, seq int) string {
+	return filename + "." + strconv.Itoa(seq)
+}
+
+// Removes both compressed and not yet compressed segment files.
+func removeSegment(segment string) {
+	segment = strings.TrimSuffix(segment, CompressedSegmentSuffix)
+	segmentsLocks.lock(segment)
+	defer segmentsLocks.unlock(segment)
+	for _, name := range []string{segment, segment + CompressedSegmentSuffix} {
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			log.Printf("Couldn't remove logs segment '%s'. %s", name, err)
+		}
+	}
+}
+
+func compressSegment(segment string) {
+	// the segment could be removed before it was compressed
+	if err := gzipFile(segment); err != nil && !os.IsNotExist(err) {
+		log.Printf("Couldn't compress logs segment '%s'. %s", segment, err)
+	}
+}
+
+// Compresses the file into the temporary file and then replaces the original file with it,
+// so readers always see either the original or the completely compressed file.
+func gzipFile(filename string) error {
+	src, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer closeFile(src)
+
+	tmpName := filename + CompressedSegmentSuffix + ".tmp"
+	dst, err := os.Create(tmpName)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if err == nil {
+		err = gz.Close()
+	}
+	closeFile(dst)
+	if err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	segmentsLocks.lock(filename)
+	defer segmentsLocks.unlock(filename)
+
+	// the segment could be removed while it was compressed
+	if _, err := os.Stat(filename); err != nil {
+		os.Remove(tmpName)
+		return nil
+	}
+	if err := os.Rename(tmpName, filename+CompressedSegmentSuffix); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return os.Remove(filename)
+}
+
+func (locks *segmentsLocksMap) lock(segment string) {
+	locks.Lock()
+	sl, ok := locks.items[segment]
+	if !ok {
+		sl = &segmentLock{}
+		locks.items[segment] = sl
+	}
+	sl.refs++
+	locks.Unlock()
+	sl.Lock()
+}
+
+func (locks *segmentsLocksMap) unlock(segment string) {
+	locks.Lock()
+	sl := locks.items[segment]
+	if sl.refs--; sl.refs == 0 {
+		delete(locks.items, segment)
+	}
+	locks.Unlock()
+	sl.Unlock()
+}
+
+// Returns names of the rotated segments of the logs file from the oldest to the latest,
+// the compressed segment file is preferred if both segment files exist.
+func listSegments(filename string) []string {
+	names, err := filepath.Glob(filename + ".[0-9]*")
+	if err != nil {
+		return nil
+	}
+	segments := make(map[int]string)
+	for _, name := range names {
+		suffix := name[len(filename)+1:]
+		compressed := strings.HasSuffix(suffix, CompressedSegmentSuffix)
+		seq, err := strconv.Atoi(strings.TrimSuffix(suffix, CompressedSegmentSuffix))
+		if err != nil {
+			continue
+		}
+		if _, ok := segments[seq]; !ok || compressed {
+			segments[seq] = name
+		}
+	}
+	seqs := make([]int, 0, len(segments))
+	for seq := range segments {
+		seqs = append(seqs, seq)
+	}
+	sort.Ints(seqs)
+	result := make([]string, len(seqs))
+	for i, seq := range seqs {
+		result[i] = segments[seq]
+	}
+	return result
+}
+
+// Opens the segment, compressed segments are decompressed while read.
+func openSegment(segment string) (io.ReadCloser, error) {
+	file, err := os.Open(segment)
+	if os.IsNotExist(err) && !strings.HasSuffix(segment, CompressedSegmentSuffix) {
+		// the segment was compressed after it was listed
+		segment += CompressedSegmentSuffix
+		file, err = os.Open(segment)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(segment, CompressedSegmentSuffix) {
+		return file, nil
+	}
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		closeFile(file)
+		return nil, fmt.Errorf("Couldn't decompress logs segment '%s'. %s", segment, err)
+	}
+	return &gzipFileReader{gz, file}, nil
+}
+
+// Returns the time of the first log in the segment,
+// false is returned if the segment is empty or can't be read.
+func firstLogTime(segment string) (time.Time, bool) {
+	reader, err := openSegment(segment)
+	if err != nil {
+		return time.Time{}, false
+	}
+	defer reader.Close()
+	message := &LogMessage{}
+	if err := json.NewDecoder(reader).Decode(message); err != nil {
+		return time.Time{}, false
+	}
+	return message.Time, true
+}
+
+type gzipFileReader struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (r *gzipFileReader) Close() error {
+	r.Reader.Close()
+	return r.file.Close()
+}
@@ -0,0 +1,214 @@
+//
+// Copyright (c) 2012-2017 Codenvy, S.A.
+// All rights reserved. This program and the accompanying materials
+// are made available under the terms of the Eclipse Public License v1.0
+// which accompanies this distribution, and is available at
+// http://www.eclipse.org/legal/epl-v10.html
+//
+// Contributors:
+//   Codenvy, S.A. - initial API and implementation
+//
+
+package process_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eclipse/che/agents/go-agents/core/process"
+)
+
+func TestLogsAreReadAcrossRotatedSegments(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "logs-rotation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	process.SetLogsRotation(32*1024, 0, 0)
+	defer process.SetLogsRotation(0, 0, 0)
+
+	filename := dir + string(os.PathSeparator) + "pid-1"
+	now := writeIndexedLogs(t, filename, 2000)
+	waitSegmentsCompressed(t, filename)
+
+	logs, err := process.NewLogsReader(filename).Till(now.Add(time.Hour)).ReadLogs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkIndexedLogs(t, logs, 0, 2000)
+
+	logs, err =
+		process.NewLogsReader(filename).
+			From(now.Add(time.Second * 500)).
+			Till(now.Add(time.Second * 1499)).
+			ReadLogs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkIndexedLogs(t, logs, 500, 1000)
+
+	logs, err =
+		process.NewLogsReader(filename).
+			Till(now.Add(time.Second * 1499)).
+			ReadTail(1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkIndexedLogs(t, logs, 500, 1000)
+}
+
+func TestOldestRotatedSegmentsAreRemoved(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "logs-rotation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	process.SetLogsRotation(32*1024, 0, 2)
+	defer process.SetLogsRotation(0, 0, 0)
+
+	filename := dir + string(os.PathSeparator) + "pid-1"
+	now := writeIndexedLogs(t, filename, 2000)
+	segments := waitSegmentsCompressed(t, filename)
+	if len(segments) != 2 {
+		t.Fatalf("Expected 2 segments to be kept but found %v", segments)
+	}
+
+	logs, err := process.NewLogsReader(filename).Till(now.Add(time.Hour)).ReadLogs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) == 0 || len(logs) >= 2000 {
+		t.Fatalf("Expected the oldest logs to be removed, but %d logs are read", len(logs))
+	}
+	checkIndexedLogs(t, logs, 2000-len(logs), len(logs))
+}
+
+func TestSegmentsRemovedWhileCompressedAreNotLeft(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "logs-rotation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	process.SetLogsRotation(1024, 0, 1)
+	defer process.SetLogsRotation(0, 0, 0)
+
+	filename := dir + string(os.PathSeparator) + "pid-1"
+	writeIndexedLogs(t, filename, 2000)
+	segments := waitSegmentsCompressed(t, filename)
+	if len(segments) != 1 {
+		t.Fatalf("Expected only the latest segment to be kept but found %v", segments)
+	}
+}
+
+func TestLogsAreReadWhileOldestSegmentsAreRemovedAndCompressed(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "logs-rotation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	process.SetLogsRotation(1024, 0, 2)
+	defer process.SetLogsRotation(0, 0, 0)
+
+	filename := dir + string(os.PathSeparator) + "pid-1"
+	done := make(chan bool)
+	go func() {
+		writeIndexedLogs(t, filename, 5000)
+		close(done)
+	}()
+
+	// segments are compressed and removed while the logs are read
+	for reading := true; reading; {
+		select {
+		case <-done:
+			reading = false
+		default:
+		}
+		logs, err := process.NewLogsReader(filename).ReadLogs()
+		if err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		for i := 1; i < len(logs); i++ {
+			if logs[i].Time.Before(logs[i-1].Time) {
+				t.Fatalf("Expected logs to be read in order, but log %d is before the previous one", i)
+			}
+		}
+	}
+
+	segments := waitSegmentsCompressed(t, filename)
+	if len(segments) != 2 {
+		t.Fatalf("Expected 2 latest segments to be kept but found %v", segments)
+	}
+	if leftovers, _ := filepath.Glob(filename + ".*.tmp"); len(leftovers) != 0 {
+		t.Fatalf("Expected no temporary files of compressed segments to be left but found %v", leftovers)
+	}
+}
+
+func TestFailedRotationIsNotRetriedOnEachFlush(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "logs-rotation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	process.SetLogsRotation(1024, 0, 0)
+	defer process.SetLogsRotation(0, 0, 0)
+
+	filename := dir + string(os.PathSeparator) + "pid-1"
+	fl, err := process.NewLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the logs file can't be renamed into the not empty directory
+	segment := filename + ".1"
+	if err := os.MkdirAll(segment+string(os.PathSeparator)+"blocker", 0777); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	text := strings.Repeat("x", 100)
+	for i := 0; i < 20; i++ {
+		fl.OnStdout(fmt.Sprintf("%d %s", i, text), now.Add(time.Second*time.Duration(i)))
+	}
+	fl.Flush()
+
+	// the next flush doesn't rotate the file even though it's possible now
+	if err := os.RemoveAll(segment); err != nil {
+		t.Fatal(err)
+	}
+	for i := 20; i < 40; i++ {
+		fl.OnStdout(fmt.Sprintf("%d %s", i, text), now.Add(time.Second*time.Duration(i)))
+	}
+	fl.Close()
+	if segments, _ := filepath.Glob(filename + ".[0-9]*"); len(segments) != 0 {
+		t.Fatalf("Expected rotation not to be retried right after it failed, but found segments %v", segments)
+	}
+
+	logs, err := process.NewLogsReader(filename).Till(now.Add(time.Hour)).ReadLogs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkIndexedLogs(t, logs, 0, 40)
+}
+
+// Waits until all the rotated segments are compressed and returns them.
+func waitSegmentsCompressed(t *testing.T, filename string) []string {
+	for i := 0; i < 100; i++ {
+		segments, err := filepath.Glob(filename + ".[0-9]*")
+		if err != nil {
+			t.Fatal(err)
+		}
+		compressed := len(segments) != 0
+		for _, segment := range segments {
+			compressed = compressed && strings.HasSuffix(segment, process.CompressedSegmentSuffix)
+		}
+		if compressed {
+			return segments
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("Rotated segments of '%s' are not compressed", filename)
+	return nil
+}
@@ -0,0 +1,234 @@
+//
+// Copyright (c) 2012-2017 Codenvy, S.A.
+// All rights reserved. This program and the accompanying materials
+// are made available under the terms of the Eclipse Public License v1.0
+// which accompanies this distribution, and is available at
+// http://www.eclipse.org/legal/epl-v10.html
+//
+// Contributors:
+//   Codenvy, S.A. - initial API and implementation
+//
+
+package process
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// LogStore keeps the logs of the processes.
+type LogStore interface {
+
+	// Create creates empty logs for the process, existing logs of the process are dropped.
+	Create(pid uint64) error
+
+	// Append appends the message to the logs of the process,
+	// appended messages may be buffered until the logs are flushed.
+	Append(pid uint64, message *LogMessage) error
+
+	// Flush makes all the appended messages of the process available for reading.
+	Flush(pid uint64) error
+
+	// Close flushes the logs of the process, nothing is appended to them after they are closed.
+	Close(pid uint64) error
+
+	// Read reads the logs of the process between [from, till] inclusive.
+	Read(pid uint64, from time.Time, till time.Time) ([]*LogMessage, error)
+
+	// ReadTail reads at most n latest logs of the process between [from, till] inclusive.
+	ReadTail(pid uint64, from time.Time, till time.Time, n int) ([]*LogMessage, error)
+
+	// Scan calls the function for each log of the process between [from, till] inclusive
+	// along with the number of its line starting from 1, until the function returns false.
+	Scan(pid uint64, from time.Time, till time.Time, f func(line int, message *LogMessage) bool) error
+
+	// Delete removes the logs of the process.
+	Delete(pid uint64) error
+
+	// Size returns the size of the logs of the process in bytes.
+	Size(pid uint64) int64
+}
+
+// FileLogStore keeps the logs of each process in its own file under the base dir,
+// see FileLogger and LogsReader for the file layout.
+type FileLogStore struct {
+	sync.RWMutex
+	baseDir     string
+	distributor LogsDistributor
+
+	// loggers of the processes which logs are not closed yet
+	loggers map[uint64]*FileLogger
+}
+
+// NewFileLogStore creates a store which distributes logs files under the base dir.
+func NewFileLogStore(baseDir string, distributor LogsDistributor) *FileLogStore {
+	return &FileLogStore{
+		baseDir:     baseDir,
+		distributor: distributor,
+		loggers:     make(map[uint64]*FileLogger),
+	}
+}
+
+// Create creates a new logs file for the process.
+func (store *FileLogStore) Create(pid uint64) error {
+	filename, err := store.filename(pid)
+	if err != nil {
+		return err
+	}
+	logger, err := NewLogger(filename)
+	if err != nil {
+		return err
+	}
+	store.Lock()
+	store.loggers[pid] = logger
+	store.Unlock()
+	return nil
+}
+
+// Append buffers the message, the buffer is written into the file when it's big enough.
+func (store *FileLogStore) Append(pid uint64, message *LogMessage) error {
+	logger, ok := store.logger(pid)
+	if !ok {
+		return fmt.Errorf("Logs of process '%d' are closed", pid)
+	}
+	logger.writeLine(message)
+	return nil
+}
+
+// Flush writes buffered messages into the logs file.
+func (store *FileLogStore) Flush(pid uint64) error {
+	if logger, ok := store.logger(pid); ok {
+		logger.Flush()
+	}
+	return nil
+}
+
+// Close writes buffered messages into the logs file and releases the logger.
+func (store *FileLogStore) Close(pid uint64) error {
+	store.Lock()
+	logger, ok := store.loggers[pid]
+	delete(store.loggers, pid)
+	store.Unlock()
+	if ok {
+		logger.Close()
+	}
+	return nil
+}
+
+// Read reads the logs file and its rotated segments.
+func (store *FileLogStore) Read(pid uint64, from time.Time, till time.Time) ([]*LogMessage, error) {
+	reader, err := store.reader(pid, from, till)
+	if err != nil {
+		return nil, err
+	}
+	return reader.ReadLogs()
+}
+
+// ReadTail reads the logs file from its end.
+func (store *FileLogStore) ReadTail(pid uint64, from time.Time, till time.Time, n int) ([]*LogMessage, error) {
+	reader, err := store.reader(pid, from, till)
+	if err != nil {
+		return nil, err
+	}
+	return reader.ReadTail(n)
+}
+
+// Scan decodes logs one by one, so they are not kept in memory.
+func (store *FileLogStore) Scan(pid uint64, from time.Time, till time.Time, f func(line int, message *LogMessage) bool) error {
+	reader, err := store.reader(pid, from, till)
+	if err != nil {
+		return err
+	}
+	return reader.Scan(f)
+}
+
+// Delete removes the logs file along with its index and rotated segments.
+func (store *FileLogStore) Delete(pid uint64) error {
+	store.Lock()
+	delete(store.loggers, pid)
+	store.Unlock()
+	filename, err := store.filename(pid)
+	if err != nil {
+		return err
+	}
+	return removeLogs(filename)
+}
+
+// Size returns the size of the logs file along with its index and rotated segments.
+func (store *FileLogStore) Size(pid uint64) int64 {
+	filename, err := store.filename(pid)
+	if err != nil {
+		return 0
+	}
+	return logsSize(filename)
+}
+
+func (store *FileLogStore) logger(pid uint64) (*FileLogger, bool) {
+	store.RLock()
+	defer store.RUnlock()
+	logger, ok := store.loggers[pid]
+	return logger, ok
+}
+
+// Creates a reader of the process logs, buffered logs are flushed first.
+func (store *FileLogStore) reader(pid uint64, from time.Time, till time.Time) (*LogsReader, error) {
+	filename, err := store.filename(pid)
+	if err != nil {
+		return nil, err
+	}
+	store.Flush(pid)
+	return NewLogsReader(filename).From(from).Till(till), nil
+}
+
+// Figures out the place of the logs file of the process.
+func (store *FileLogStore) filename(pid uint64) (string, error) {
+	dir, err := store.distributor.DirForPid(store.baseDir, pid)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s%cpid-%d", dir, os.PathSeparator, pid), nil
+}
+
+// Appends the process output to the store, added as a consumer of the process pumper.
+type logsStoreWriter struct {
+	store LogStore
+	pid   uint64
+	mode  string
+
+	// whether escape sequences are stripped from the output before it's appended
+	stripAnsi bool
+}
+
+func newLogsStoreWriter(store LogStore, p *MachineProcess) *logsStoreWriter {
+	mode := outputMode(p.Output)
+	return &logsStoreWriter{
+		store: store,
+		pid:   p.Pid,
+		mode:  mode,
+		// the output of raw mode is encoded, so it's never stripped
+		stripAnsi: p.StripAnsi && mode != RawOutputMode,
+	}
+}
+
+func (w *logsStoreWriter) OnStdout(line string, time time.Time) {
+	w.append(StdoutKind, line, time)
+}
+
+func (w *logsStoreWriter) OnStderr(line string, time time.Time) {
+	w.append(StderrKind, line, time)
+}
+
+func (w *logsStoreWriter) append(kind LogKind, text string, time time.Time) {
+	if w.stripAnsi {
+		text = StripAnsi(text)
+	}
+	w.store.Append(w.pid, &LogMessage{Kind: kind, Time: time, Text: text, Mode: w.mode})
+}
+
+// Only flushes the logs as the process may be restarted and pumped again,
+// the logs are closed when the process dies.
+func (w *logsStoreWriter) Close() {
+	w.store.Flush(w.pid)
+}
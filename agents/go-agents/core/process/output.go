@@ -0,0 +1,252 @@
+//
+// Copyright (c) 2012-2017 Codenvy, S.A.
+// All rights reserved. This program and the accompanying materials
+// are made available under the terms of the Eclipse Public License v1.0
+// which accompanies this distribution, and is available at
+// http://www.eclipse.org/legal/epl-v10.html
+//
+// Contributors:
+//   Codenvy, S.A. - initial API and implementation
+//
+
+package process
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Output modes define how the process output is split into events and logs.
+const (
+	// LinesOutputMode splits the output by new lines, too long lines are truncated.
+	LinesOutputMode = "lines"
+
+	// ChunksOutputMode passes the output as is in chunks, so partial lines
+	// such as '\r' progress updates are streamed without waiting for a new line.
+	ChunksOutputMode = "chunks"
+
+	// RawOutputMode passes the output in chunks encoded by base64,
+	// so the output which is not valid UTF-8 isn't corrupted.
+	RawOutputMode = "raw"
+)
+
+const (
+	// DefaultMaxLineLength is the max length of the line in bytes in the lines mode.
+	DefaultMaxLineLength = 64 * 1024
+
+	// DefaultChunkSize is the max size of the chunk in bytes in the chunks and raw modes.
+	DefaultChunkSize = 4096
+
+	// DefaultChunkInterval is the max time in milliseconds the output
+	// is held before it is passed in the chunks and raw modes.
+	DefaultChunkInterval = 100
+
+	// TruncationMarker is appended to the line truncated in the lines mode.
+	TruncationMarker = "...[truncated]"
+)
+
+// OutputOptions defines how the process output is captured.
+type OutputOptions struct {
+	// One of LinesOutputMode, ChunksOutputMode, RawOutputMode.
+	// If empty then LinesOutputMode is used.
+	Mode string `json:"mode,omitempty"`
+
+	// The max length of the line in bytes, the rest of the line is dropped and
+	// TruncationMarker is appended instead. If zero then DefaultMaxLineLength is used.
+	MaxLineLength int `json:"maxLineLength,omitempty"`
+
+	// The max size of the chunk in bytes, if zero then DefaultChunkSize is used.
+	ChunkSize int `json:"chunkSize,omitempty"`
+
+	// The max time in milliseconds the output is held before it is passed
+	// even if the chunk isn't full. If zero then DefaultChunkInterval is used.
+	ChunkInterval int `json:"chunkInterval,omitempty"`
+}
+
+// Validate checks whether the output options are valid.
+func (options *OutputOptions) Validate() error {
+	switch options.Mode {
+	case "", LinesOutputMode, ChunksOutputMode, RawOutputMode:
+	default:
+		return fmt.Errorf("Output mode '%s' is not supported", options.Mode)
+	}
+	if options.MaxLineLength < 0 {
+		return errors.New("Max line length must be >= 0")
+	}
+	if options.ChunkSize < 0 {
+		return errors.New("Chunk size must be >= 0")
+	}
+	if options.ChunkInterval < 0 {
+		return errors.New("Chunk interval must be >= 0")
+	}
+	return nil
+}
+
+// Returns the options with all the defaults applied, nil options mean defaults.
+func (options *OutputOptions) withDefaults() OutputOptions {
+	result := OutputOptions{}
+	if options != nil {
+		result = *options
+	}
+	if result.Mode == "" {
+		result.Mode = LinesOutputMode
+	}
+	if result.MaxLineLength == 0 {
+		result.MaxLineLength = DefaultMaxLineLength
+	}
+	if result.ChunkSize == 0 {
+		result.ChunkSize = DefaultChunkSize
+	}
+	if result.ChunkInterval == 0 {
+		result.ChunkInterval = DefaultChunkInterval
+	}
+	return result
+}
+
+// Returns the mode events and logs of the process output are marked with,
+// the lines mode is not marked so the logs written before modes appeared look the same.
+func outputMode(options *OutputOptions) string {
+	if options == nil || options.Mode == LinesOutputMode {
+		return ""
+	}
+	return options.Mode
+}
+
+// Reads lines passing them to the consumer without new line characters,
+// the lines longer than max length are truncated.
+func pumpLines(r io.Reader, maxLength int, consumer acceptLine) {
+	br := bufio.NewReader(r)
+	line := make([]byte, 0)
+	truncated := false
+	for {
+		part, err := br.ReadSlice('\n')
+		if err == nil {
+			part = part[:len(part)-1]
+		}
+		if !truncated {
+			line = append(line, part...)
+			if len(line) > maxLength {
+				line = line[:maxLength-incompleteRuneSize(line[:maxLength])]
+				truncated = true
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil && err != io.EOF {
+			log.Println("Error pumping: " + err.Error())
+			return
+		}
+		if err == nil || len(line) != 0 || truncated {
+			if truncated {
+				consumer(string(line) + TruncationMarker)
+			} else {
+				consumer(string(line))
+			}
+		}
+		if err != nil {
+			return
+		}
+		line = line[:0]
+		truncated = false
+	}
+}
+
+// Reads the output passing it to the consumer in chunks of at most size bytes,
+// the chunk is passed when it's full or when the interval elapses after the first byte
+// of the chunk was read. Chunks are encoded by base64 if encode is true,
+// otherwise incomplete UTF-8 characters are held till the next chunk.
+func pumpChunks(r io.Reader, size int, interval time.Duration, encode bool, consumer acceptLine) {
+	var mutex sync.Mutex
+	chunk := make([]byte, 0, size)
+
+	// timer flushes the chunk only if it wasn't flushed since the timer was scheduled
+	var timer *time.Timer
+	var generation int
+	flush := func() {
+		held := 0
+		if !encode {
+			held = incompleteRuneSize(chunk)
+		}
+		if held == len(chunk) && held == size {
+			// the chunk is too small to hold the character
+			held = 0
+		}
+		if len(chunk) > held {
+			data := chunk[:len(chunk)-held]
+			if encode {
+				consumer(base64.StdEncoding.EncodeToString(data))
+			} else {
+				consumer(string(data))
+			}
+			chunk = append(chunk[:0], chunk[len(chunk)-held:]...)
+		}
+		if timer != nil {
+			timer.Stop()
+			timer = nil
+		}
+		generation++
+	}
+
+	buffer := make([]byte, size)
+	for {
+		n, err := r.Read(buffer)
+		mutex.Lock()
+		data := buffer[:n]
+		for len(data) != 0 {
+			free := size - len(chunk)
+			if free > len(data) {
+				free = len(data)
+			}
+			chunk = append(chunk, data[:free]...)
+			data = data[free:]
+			if len(chunk) == size {
+				flush()
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				log.Println("Error pumping: " + err.Error())
+			}
+			flush()
+			// the incomplete character held by flush is never completed
+			if len(chunk) != 0 {
+				consumer(string(chunk))
+			}
+			mutex.Unlock()
+			return
+		}
+		if len(chunk) != 0 && timer == nil {
+			scheduled := generation
+			timer = time.AfterFunc(interval, func() {
+				mutex.Lock()
+				if scheduled == generation {
+					flush()
+				}
+				mutex.Unlock()
+			})
+		}
+		mutex.Unlock()
+	}
+}
+
+// Returns the number of bytes of the incomplete UTF-8 character the data ends with.
+func incompleteRuneSize(data []byte) int {
+	for i := 1; i < utf8.UTFMax && i <= len(data); i++ {
+		b := data[len(data)-i]
+		if utf8.RuneStart(b) {
+			if !utf8.FullRune(data[len(data)-i:]) {
+				return i
+			}
+			return 0
+		}
+	}
+	return 0
+}
@@ -0,0 +1,111 @@
+//
+// Copyright (c) 2012-2017 Codenvy, S.A.
+// All rights reserved. This program and the accompanying materials
+// are made available under the terms of the Eclipse Public License v1.0
+// which accompanies this distribution, and is available at
+// http://www.eclipse.org/legal/epl-v10.html
+//
+// Contributors:
+//   Codenvy, S.A. - initial API and implementation
+//
+
+package process
+
+import (
+	"encoding/base64"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPumpLinesTruncatesLongLines(t *testing.T) {
+	var lines []string
+	input := "short\n" + strings.Repeat("x", 10) + "\n\nlast"
+	pumpLines(strings.NewReader(input), 4, func(line string) { lines = append(lines, line) })
+
+	expected := []string{"shor" + TruncationMarker, "xxxx" + TruncationMarker, "", "last"}
+	if strings.Join(lines, "|") != strings.Join(expected, "|") {
+		t.Fatalf("Expected lines %q but got %q", expected, lines)
+	}
+}
+
+func TestPumpLinesDoesNotSplitCharacters(t *testing.T) {
+	var lines []string
+	pumpLines(strings.NewReader("aбв\n"), 4, func(line string) { lines = append(lines, line) })
+
+	if len(lines) != 1 || lines[0] != "aб"+TruncationMarker {
+		t.Fatalf("Expected the line to be truncated before incomplete character but got %q", lines)
+	}
+}
+
+func TestPumpChunksPassesPartialLineAfterInterval(t *testing.T) {
+	r, w := io.Pipe()
+	chunks := make(chan string, 10)
+	done := make(chan bool)
+	go func() {
+		pumpChunks(r, 1024, 20*time.Millisecond, false, func(chunk string) { chunks <- chunk })
+		done <- true
+	}()
+
+	w.Write([]byte("progress 10%\r"))
+	select {
+	case chunk := <-chunks:
+		if chunk != "progress 10%\r" {
+			t.Fatalf("Expected chunk 'progress 10%%\\r' but got %q", chunk)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the partial line to be passed after the interval")
+	}
+
+	w.Write([]byte("progress 20%\r"))
+	w.Close()
+	<-done
+	if chunk := <-chunks; chunk != "progress 20%\r" {
+		t.Fatalf("Expected the rest of the output to be passed on close but got %q", chunk)
+	}
+}
+
+func TestPumpChunksHoldsIncompleteCharacters(t *testing.T) {
+	var chunks []string
+	pumpChunks(strings.NewReader("abв"), 3, time.Minute, false, func(chunk string) { chunks = append(chunks, chunk) })
+
+	if len(chunks) != 2 || chunks[0] != "ab" || chunks[1] != "в" {
+		t.Fatalf("Expected chunks 'ab' and 'в' but got %q", chunks)
+	}
+}
+
+func TestPumpRawChunksAreEncoded(t *testing.T) {
+	input := []byte{0xff, 0xfe, 'a', '\n', 0x00}
+	var chunks []string
+	pumpChunks(strings.NewReader(string(input)), 3, time.Minute, true, func(chunk string) { chunks = append(chunks, chunk) })
+
+	decoded := ""
+	for _, chunk := range chunks {
+		data, err := base64.StdEncoding.DecodeString(chunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded += string(data)
+	}
+	if len(chunks) != 2 || decoded != string(input) {
+		t.Fatalf("Expected 2 chunks which decode to the input but got %q", chunks)
+	}
+}
+
+func TestOutputOptionsValidation(t *testing.T) {
+	invalid := []*OutputOptions{
+		{Mode: "bytes"},
+		{MaxLineLength: -1},
+		{ChunkSize: -1},
+		{ChunkInterval: -1},
+	}
+	for _, options := range invalid {
+		if err := options.Validate(); err == nil {
+			t.Fatalf("Expected options %v to be invalid", options)
+		}
+	}
+	if err := (&OutputOptions{Mode: RawOutputMode}).Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
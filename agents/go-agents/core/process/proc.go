@@ -0,0 +1,190 @@
+//
+// Copyright (c) 2012-2017 Codenvy, S.A.
+// All rights reserved. This program and the accompanying materials
+// are made available under the terms of the Eclipse Public License v1.0
+// which accompanies this distribution, and is available at
+// http://www.eclipse.org/legal/epl-v10.html
+//
+// Contributors:
+//   Codenvy, S.A. - initial API and implementation
+//
+
+package process
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// the number of clock ticks per second used by /proc/{pid}/stat times(USER_HZ)
+	clockTicksPerSecond = 100
+)
+
+var (
+	// the mount point of procfs
+	procDir = "/proc"
+)
+
+// procStat is a subset of /proc/{pid}/stat fields, see 'man proc'.
+type procStat struct {
+	pid       int
+	comm      string
+	state     string
+	ppid      int
+	pgrp      int
+	session   int
+	utime     uint64
+	stime     uint64
+	threads   int
+	startTime uint64
+	rss       int64
+}
+
+// Reads and parses /proc/{pid}/stat of the native process with given pid.
+func readProcStat(pid int) (*procStat, error) {
+	content, err := ioutil.ReadFile(fmt.Sprintf("%s/%d/stat", procDir, pid))
+	if err != nil {
+		return nil, err
+	}
+	return parseProcStat(string(content))
+}
+
+func parseProcStat(content string) (*procStat, error) {
+	// comm is in parentheses and may contain spaces and parentheses itself
+	open := strings.IndexByte(content, '(')
+	closing := strings.LastIndexByte(content, ')')
+	if open < 0 || closing < open {
+		return nil, fmt.Errorf("Bad format of process stat '%s'", content)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(content[:open]))
+	if err != nil {
+		return nil, err
+	}
+
+	// fields[0] is the 3rd field of stat - state
+	fields := strings.Fields(content[closing+1:])
+	if len(fields) < 22 {
+		return nil, fmt.Errorf("Bad format of process stat '%s'", content)
+	}
+	stat := &procStat{
+		pid:   pid,
+		comm:  content[open+1 : closing],
+		state: fields[0],
+	}
+	ints := []*int{&stat.ppid, &stat.pgrp, &stat.session}
+	for i, v := range ints {
+		if *v, err = strconv.Atoi(fields[i+1]); err != nil {
+			return nil, err
+		}
+	}
+	if stat.utime, err = strconv.ParseUint(fields[11], 10, 64); err != nil {
+		return nil, err
+	}
+	if stat.stime, err = strconv.ParseUint(fields[12], 10, 64); err != nil {
+		return nil, err
+	}
+	if stat.threads, err = strconv.Atoi(fields[17]); err != nil {
+		return nil, err
+	}
+	if stat.startTime, err = strconv.ParseUint(fields[19], 10, 64); err != nil {
+		return nil, err
+	}
+	if stat.rss, err = strconv.ParseInt(fields[21], 10, 64); err != nil {
+		return nil, err
+	}
+	return stat, nil
+}
+
+// Returns stats of all the native processes which belong to the session with given id.
+// Processes which exit while reading are skipped.
+func sessionProcStats(sid int) ([]*procStat, error) {
+	all, err := allProcStats()
+	if err != nil {
+		return nil, err
+	}
+	stats := []*procStat{}
+	for _, stat := range all {
+		if stat.session == sid {
+			stats = append(stats, stat)
+		}
+	}
+	return stats, nil
+}
+
+// Returns stats of all the native processes visible in /proc.
+// Processes which exit while reading are skipped.
+func allProcStats() ([]*procStat, error) {
+	entries, err := ioutil.ReadDir(procDir)
+	if err != nil {
+		return nil, err
+	}
+	stats := []*procStat{}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		stat, err := readProcStat(pid)
+		if err != nil {
+			continue
+		}
+		stats = append(stats, stat)
+	}
+	return stats, nil
+}
+
+// Reads the command line arguments of the native process with given pid.
+// Returns an empty slice for zombies and kernel threads.
+func readCmdline(pid int) []string {
+	content, err := ioutil.ReadFile(fmt.Sprintf("%s/%d/cmdline", procDir, pid))
+	if err != nil {
+		return []string{}
+	}
+	return parseCmdline(string(content))
+}
+
+func parseCmdline(content string) []string {
+	content = strings.TrimRight(content, "\x00")
+	if content == "" {
+		return []string{}
+	}
+	return strings.Split(content, "\x00")
+}
+
+// Reads the system boot time from /proc/stat.
+func readBootTime() (time.Time, error) {
+	content, err := ioutil.ReadFile(procDir + "/stat")
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.HasPrefix(line, "btime ") {
+			sec, err := strconv.ParseInt(strings.TrimSpace(line[len("btime "):]), 10, 64)
+			if err != nil {
+				return time.Time{}, err
+			}
+			return time.Unix(sec, 0), nil
+		}
+	}
+	return time.Time{}, errors.New("Boot time is missing in /proc/stat")
+}
+
+// Counts open file descriptors of the native process with given pid.
+func countOpenFiles(pid int) int {
+	dir, err := os.Open(fmt.Sprintf("%s/%d/fd", procDir, pid))
+	if err != nil {
+		return 0
+	}
+	defer closeFile(dir)
+	names, err := dir.Readdirnames(-1)
+	if err != nil {
+		return 0
+	}
+	return len(names)
+}
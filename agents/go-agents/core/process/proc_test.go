@@ -0,0 +1,59 @@
+//
+// Copyright (c) 2012-2017 Codenvy, S.A.
+// All rights reserved. This program and the accompanying materials
+// are made available under the terms of the Eclipse Public License v1.0
+// which accompanies this distribution, and is available at
+// http://www.eclipse.org/legal/epl-v10.html
+//
+// Contributors:
+//   Codenvy, S.A. - initial API and implementation
+//
+
+package process
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseProcStat(t *testing.T) {
+	content := "1234 (my (weird) cmd) S 1000 1234 1234 0 -1 4194560 120 0 0 0 15 7 0 0 20 0 3 0 98765 10485760 256 18446744073709551615"
+
+	stat, err := parseProcStat(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := procStat{
+		pid:       1234,
+		comm:      "my (weird) cmd",
+		state:     "S",
+		ppid:      1000,
+		pgrp:      1234,
+		session:   1234,
+		utime:     15,
+		stime:     7,
+		threads:   3,
+		startTime: 98765,
+		rss:       256,
+	}
+	if *stat != expected {
+		t.Fatalf("Expected %v but got %v", expected, *stat)
+	}
+}
+
+func TestParseProcStatFailsOnBadFormat(t *testing.T) {
+	if _, err := parseProcStat("1234 cmd S 1"); err == nil {
+		t.Fatal("Expected error when parsing bad formatted stat")
+	}
+}
+
+func TestParseCmdline(t *testing.T) {
+	argv := parseCmdline("sleep\x0010\x00")
+	if !reflect.DeepEqual(argv, []string{"sleep", "10"}) {
+		t.Fatalf("Expected [sleep 10] but got %v", argv)
+	}
+	if argv := parseCmdline(""); len(argv) != 0 {
+		t.Fatalf("Expected empty argv but got %v", argv)
+	}
+}
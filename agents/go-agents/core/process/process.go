@@ -14,9 +14,11 @@ package process
 import (
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"syscall"
@@ -30,12 +32,18 @@ const (
 	StderrBit = 1 << iota
 	// StatusBit is set when subscriber is interested in a process events
 	StatusBit = 1 << iota
+	// StatsBit is set when subscriber is interested in periodic process resource usage events
+	StatsBit = 1 << iota
 	// DefaultMask is set by default and identifies receiving of both logs and events of a process
 	DefaultMask = StderrBit | StdoutBit | StatusBit
 
 	// DefaultShellInterpreter is default shell that executes commands
 	// unless another one is configured
 	DefaultShellInterpreter = "/bin/bash"
+
+	// DefaultKillGracePeriod is how much time process has to terminate
+	// after SIGTERM is sent and before SIGKILL is sent on graceful kill
+	DefaultKillGracePeriod = 10 * time.Second
 )
 
 var (
@@ -50,13 +58,32 @@ var (
 	// used by process to point to the file for a process with given pid
 	logsDistributor LogsDistributor = NewLogsDistributor()
 
+	// the store new processes keep their logs in, logs are kept in memory unless logs dir is set
+	logStore LogStore = NewMemoryLogStore(DefaultMemoryLogsCapacity)
+
 	// shell that executes commands
 	shellInterpreter = DefaultShellInterpreter
+
+	// timeout in seconds applied to the processes which don't specify their own one,
+	// non positive value means that such processes are never timed out
+	defaultTimeout int
 )
 
-// SetLogsDir sets the path to the directory to write logs to.
+// SetLogsDir sets the path to the directory to write logs to,
+// if the dir is empty then logs are kept in memory.
 func SetLogsDir(dir string) {
 	logsDir = dir
+	if dir == "" {
+		logStore = NewMemoryLogStore(DefaultMemoryLogsCapacity)
+	} else {
+		logStore = NewFileLogStore(dir, logsDistributor)
+	}
+}
+
+// SetLogStore changes the store the logs of new processes are kept in,
+// nil store means that logs are not kept at all.
+func SetLogStore(store LogStore) {
+	logStore = store
 }
 
 // WipeLogs removes logs dir and all the files and directories under it.
@@ -68,6 +95,9 @@ func WipeLogs() error {
 func SetLogsDistributor(ld LogsDistributor) {
 	if ld != nil {
 		logsDistributor = ld
+		if logsDir != "" {
+			logStore = NewFileLogStore(logsDir, ld)
+		}
 	}
 }
 
@@ -79,11 +109,89 @@ func SetShellInterpreter(si string) {
 	}
 }
 
+// SetDefaultTimeout sets the timeout in seconds which is applied
+// to the processes started without their own timeout.
+func SetDefaultTimeout(timeout int) {
+	defaultTimeout = timeout
+}
+
 // Command represents command that is used in command execution API.
 type Command struct {
 	Name        string `json:"name"`
 	CommandLine string `json:"commandLine"`
 	Type        string `json:"type"`
+
+	// The name of the interpreter which executes the command line, it must be allowed
+	// by SetAllowedInterpreters. If empty then the default shell interpreter is used.
+	Interpreter string `json:"interpreter,omitempty"`
+
+	// The program followed by its arguments which is executed directly without
+	// any interpreter, can't be used along with the command line and interpreter.
+	Argv []string `json:"argv,omitempty"`
+
+	// Environment variables which are set for the process in addition
+	// to the agent's environment, or instead of it if CleanEnv is true.
+	Env map[string]string `json:"env,omitempty"`
+
+	// Whether the process should start with an empty environment
+	// containing only variables from Env.
+	CleanEnv bool `json:"cleanEnv,omitempty"`
+
+	// The directory the command is executed in,
+	// if empty then the agent's working directory is used.
+	WorkingDir string `json:"workingDir,omitempty"`
+
+	// Whether the input of the process may be written by WriteInput,
+	// if false then the process reads its input from /dev/null.
+	// The process which runs under a pseudo-terminal always accepts the input.
+	Stdin bool `json:"stdin,omitempty"`
+
+	// The time in seconds after which the process is terminated,
+	// if zero then the default timeout is used, if any configured.
+	Timeout int `json:"timeout,omitempty"`
+
+	// The policy of the process restart, one of RestartNever, RestartOnFailure, RestartAlways.
+	// If empty then the process is never restarted.
+	RestartPolicy string `json:"restartPolicy,omitempty"`
+
+	// How many times the process may be restarted, if zero then there is no limit.
+	MaxRetries int `json:"maxRetries,omitempty"`
+
+	// The delay in milliseconds before the first restart, the delay doubles
+	// for each next restart. If zero then DefaultRestartBackoff is used.
+	RestartBackoff int `json:"restartBackoff,omitempty"`
+
+	// The criteria of the process readiness, if nil then
+	// the process is considered ready as soon as it is started.
+	Readiness *ReadinessProbe `json:"readiness,omitempty"`
+
+	// The resources available to the process and its descendants,
+	// if nil then the process is not limited.
+	Limits *Limits `json:"limits,omitempty"`
+
+	// Defines how the output of the process is captured,
+	// if nil then the output is split into lines.
+	Output *OutputOptions `json:"output,omitempty"`
+
+	// Whether the process runs under a pseudo-terminal, in this case
+	// stdout and stderr of the process are merged into stdout.
+	Tty bool `json:"tty,omitempty"`
+
+	// The window size of the process terminal,
+	// if zero then DefaultTtyCols and DefaultTtyRows are used.
+	Cols int `json:"cols,omitempty"`
+	Rows int `json:"rows,omitempty"`
+
+	// Whether ANSI escape sequences are stripped from the output before it's written
+	// into the logs, the subscribers still receive the output as is.
+	StripAnsi bool `json:"stripAnsi,omitempty"`
+
+	// The user the process runs as, either user name or uid optionally followed
+	// by ':' and group name or gid. If empty then the process runs as the agent's user.
+	User string `json:"user,omitempty"`
+
+	// Arbitrary labels which may be used for processes filtering.
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
 // MachineProcess defines machine process model.
@@ -106,6 +214,95 @@ type MachineProcess struct {
 	// to the Command.Type which this process created from.
 	Type string `json:"type"`
 
+	// The name of the interpreter which executes the command line.
+	// It is equal to the Command.Interpreter which this process created from.
+	Interpreter string `json:"interpreter,omitempty"`
+
+	// The program and its arguments executed without interpreter.
+	// It is equal to the Command.Argv which this process created from.
+	Argv []string `json:"argv,omitempty"`
+
+	// Environment variables set for the process.
+	// It is equal to the Command.Env which this process created from.
+	Env map[string]string `json:"env,omitempty"`
+
+	// Whether the process was started with a clean environment.
+	// It is equal to the Command.CleanEnv which this process created from.
+	CleanEnv bool `json:"cleanEnv,omitempty"`
+
+	// The directory the process is executed in.
+	// It is equal to the Command.WorkingDir which this process created from.
+	WorkingDir string `json:"workingDir,omitempty"`
+
+	// Whether the input of the process may be written.
+	// It is equal to the Command.Stdin which this process created from.
+	Stdin bool `json:"stdin,omitempty"`
+
+	// The time in seconds after which the process is terminated.
+	// It is equal to the Command.Timeout or to the default timeout if the command doesn't have one.
+	Timeout int `json:"timeout,omitempty"`
+
+	// The policy of the process restart.
+	// It is equal to the Command.RestartPolicy which this process created from.
+	RestartPolicy string `json:"restartPolicy,omitempty"`
+
+	// How many times the process may be restarted.
+	// It is equal to the Command.MaxRetries which this process created from.
+	MaxRetries int `json:"maxRetries,omitempty"`
+
+	// The delay in milliseconds before the first restart.
+	// It is equal to the Command.RestartBackoff which this process created from.
+	RestartBackoff int `json:"restartBackoff,omitempty"`
+
+	// The criteria of the process readiness.
+	// It is equal to the Command.Readiness which this process created from.
+	Readiness *ReadinessProbe `json:"readiness,omitempty"`
+
+	// The resources available to the process and its descendants.
+	// It is equal to the Command.Limits which this process created from.
+	Limits *Limits `json:"limits,omitempty"`
+
+	// Defines how the output of the process is captured.
+	// It is equal to the Command.Output which this process created from.
+	Output *OutputOptions `json:"output,omitempty"`
+
+	// Whether the process runs under a pseudo-terminal.
+	// It is equal to the Command.Tty which this process created from.
+	Tty bool `json:"tty,omitempty"`
+
+	// The window size of the process terminal, it is initially equal
+	// to the Command.Cols and Command.Rows and changed by Resize.
+	Cols int `json:"cols,omitempty"`
+	Rows int `json:"rows,omitempty"`
+
+	// Whether ANSI escape sequences are stripped from the logs of the process.
+	// It is equal to the Command.StripAnsi which this process created from.
+	StripAnsi bool `json:"stripAnsi,omitempty"`
+
+	// The user the process runs as.
+	// It is equal to the Command.User which this process created from.
+	User string `json:"user,omitempty"`
+
+	// The effective uid of the process.
+	UID int `json:"uid"`
+
+	// Arbitrary labels which may be used for processes filtering.
+	// It is equal to the Command.Labels which this process created from.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// The id of the chain which started this process as its step,
+	// the value is zero if the process is not a chain step.
+	ParentChain uint64 `json:"parentChain,omitempty"`
+
+	// Whether the process is ready, the value is set once the readiness
+	// probe succeeds. Processes without readiness probe are ready once started.
+	// The value is reset when the process is restarted, as the probe checks each run.
+	Ready bool `json:"ready"`
+
+	// How many times the process was restarted.
+	// The process keeps its pid across restarts while its NativePid changes.
+	RestartCount int `json:"restartCount"`
+
 	// Whether this process is alive or dead.
 	Alive bool `json:"alive"`
 
@@ -117,8 +314,13 @@ type MachineProcess struct {
 	// The value is set after the process died, the value is -1 while the process is alive.
 	ExitCode int `json:"exitCode"`
 
-	// Process log filename.
-	logfileName string
+	// The name of the signal which terminated the process e.g. 'KILL'.
+	// The value is empty if process is alive or exited normally.
+	Signal string `json:"signal,omitempty"`
+
+	// The reason of the process death, one of ExitedReason, KilledReason,
+	// SignaledReason, TimeoutReason, OOMReason, LostReason. The value is empty while the process is alive.
+	Reason string `json:"reason,omitempty"`
 
 	// Command executed by this process.
 	// If process is not alive then the command value is set to nil.
@@ -128,21 +330,55 @@ type MachineProcess struct {
 	// If process is not alive then the pumper value is set to nil.
 	pumper *LogsPumper
 
+	// Stdin of the process.
+	// If process is not alive or its input is closed then the stdin value is set to nil.
+	stdin io.WriteCloser
+
 	// Process subscribers, all the outgoing events are go through those subscribers.
 	// If process is not alive then the subscribers value is set to nil.
 	subs []*Subscriber
 
-	// Process file logger.
-	// The value is set only if process logs directory is configured.
-	fileLogger *FileLogger
+	// The store process logs are kept in, nil if logs are not kept.
+	logs LogStore
 
 	// Process mutex should be used to sync process data
 	// or block on process related operations such as events publications.
 	mutex *sync.RWMutex
 
+	// The time when the process started.
+	startTime time.Time
+
 	// The time when the process died.
 	deathTime time.Time
 
+	// Terminates the process when its timeout elapses.
+	// If process doesn't have a timeout or is not alive then the value is set to nil.
+	timeoutTimer *time.Timer
+
+	// Whether the process was terminated because of the timeout.
+	timedOut bool
+
+	// Whether the last native process was killed because it exceeded its memory limit.
+	oomKilled bool
+
+	// Closed when the process dies, stops the routines which live while the process is alive.
+	done chan bool
+
+	// Checks the process readiness, the value is set only if process has readiness probe.
+	readiness *readinessChecker
+
+	// Whether the process was stopped by kill or timeout, stopped process is never restarted.
+	stopped bool
+
+	// Starts the native process again when restart backoff elapses.
+	// If process is not waiting for restart then the value is set to nil.
+	restartTimer *time.Timer
+
+	// The exit code and the signal of the last native process
+	// which died while the process waits for restart.
+	lastExitCode int
+	lastSignal   string
+
 	// Called once before any of process events is published
 	// and after process is started.
 	beforeEventsHook func(process MachineProcess)
@@ -167,6 +403,12 @@ type NotAliveError struct {
 	Pid uint64
 }
 
+// AliveError is returned when process that is target of an action is still alive.
+type AliveError struct {
+	error
+	Pid uint64
+}
+
 // Lockable map for storing processes.
 type processesMap struct {
 	sync.RWMutex
@@ -175,23 +417,17 @@ type processesMap struct {
 
 // Start starts MachineProcess.
 func Start(newProcess MachineProcess) (MachineProcess, error) {
-	// wrap command to be able to kill child processes see https://github.com/golang/go/issues/8854
-	cmd := exec.Command("setsid", shellInterpreter, "-c", newProcess.CommandLine)
-
-	// getting stdout pipe
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return newProcess, err
-	}
-
-	// getting stderr pipe
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		return newProcess, err
+	// the probe is checked before the native process is started, so it's never left behind
+	if newProcess.Readiness != nil {
+		if err := newProcess.Readiness.Validate(); err != nil {
+			return newProcess, err
+		}
+		if err := CheckReadinessOutput(newProcess.Readiness, newProcess.Output); err != nil {
+			return newProcess, err
+		}
 	}
 
-	// starting a new process
-	err = cmd.Start()
+	cmd, stdin, pumper, err := startCommand(newProcess)
 	if err != nil {
 		return newProcess, err
 	}
@@ -203,36 +439,55 @@ func Start(newProcess MachineProcess) (MachineProcess, error) {
 	newProcess.Pid = pid
 	newProcess.Alive = true
 	newProcess.NativePid = cmd.Process.Pid
+	newProcess.UID = effectiveUID(cmd)
 	newProcess.ExitCode = -1
+	newProcess.Ready = newProcess.Readiness == nil
+	if newProcess.Timeout <= 0 && defaultTimeout > 0 {
+		newProcess.Timeout = defaultTimeout
+	}
 
 	// create an internal copy of the new process
 	internalProcess := newProcess
 
-	pumper := NewPumper(stdout, stderr)
-	fileLogger, err := newFileLogger(pid)
-	if err != nil {
-		return newProcess, err
+	logs := logStore
+	if logs != nil {
+		if err := logs.Create(pid); err != nil {
+			abandonCommand(cmd, pumper)
+			return newProcess, err
+		}
 	}
 
 	// set internal data
 	internalProcess.command = cmd
 	internalProcess.pumper = pumper
+	internalProcess.stdin = stdin
 	internalProcess.mutex = &sync.RWMutex{}
-	if fileLogger != nil {
-		internalProcess.fileLogger = fileLogger
-		internalProcess.logfileName = fileLogger.filename
+	internalProcess.done = make(chan bool)
+	internalProcess.startTime = time.Now()
+	internalProcess.logs = logs
+
+	// prepare readiness checks, output lines are checked by the process itself
+	if newProcess.Readiness != nil {
+		internalProcess.readiness = newReadinessChecker(&internalProcess, *newProcess.Readiness)
 	}
 
 	// register logs consumers
-	if fileLogger != nil {
-		pumper.AddConsumer(fileLogger)
+	if logs != nil {
+		pumper.AddConsumer(newLogsStoreWriter(logs, &internalProcess))
 	}
 	pumper.AddConsumer(&internalProcess)
 
+	// schedule termination of the process when its timeout elapses
+	if newProcess.Timeout > 0 {
+		timeout := time.Duration(newProcess.Timeout) * time.Second
+		internalProcess.timeoutTimer = time.AfterFunc(timeout, internalProcess.onTimeout)
+	}
+
 	// save(publish) process instance
 	processes.Lock()
 	processes.items[pid] = &internalProcess
 	processes.Unlock()
+	persistProcesses()
 
 	if newProcess.beforeEventsHook != nil {
 		newProcess.beforeEventsHook(newProcess)
@@ -248,12 +503,113 @@ func Start(newProcess MachineProcess) (MachineProcess, error) {
 	// start pumping after start event is published 'pumper.Pump' is blocking
 	go func() {
 		<-startPublished
+		if internalProcess.readiness != nil {
+			internalProcess.readiness.start()
+		}
+		go internalProcess.publishStatsPeriodically(internalProcess.done)
 		pumper.Pump()
 	}()
 
 	return newProcess, nil
 }
 
+// Starts the native process which executes the command line of given process.
+// Returns the started command, its stdin and the pumper of its stdout and stderr.
+func startCommand(p MachineProcess) (*exec.Cmd, io.WriteCloser, *LogsPumper, error) {
+	args, err := commandArgs(p)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	// wrap command to be able to kill child processes see https://github.com/golang/go/issues/8854
+	cmd := exec.Command("setsid", args...)
+	if p.Tty {
+		// the new session is created by startTty, as the terminal must be its controlling terminal
+		cmd = exec.Command(args[0], args[1:]...)
+	}
+	cmd.Dir = p.WorkingDir
+	var userEnv map[string]string
+	if p.User != "" {
+		ra, err := resolveUser(p.User)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		cmd.SysProcAttr = &syscall.SysProcAttr{Credential: ra.credential()}
+		userEnv = ra.env()
+	}
+	cmd.Env = envOf(p, userEnv)
+
+	if p.Tty {
+		cmd.Env = ttyEnv(cmd.Env, p)
+		releaseLimited, err := limitCommand(cmd, p.Limits)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		tty, err := startTty(cmd, p.Cols, p.Rows)
+		if limitErr := releaseLimited(); err == nil && limitErr != nil {
+			closeFile(tty)
+			cmd.Wait()
+			return nil, nil, nil, limitErr
+		}
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		pumper := NewPumper(&ttyOutput{tty}, nil)
+		pumper.tty = tty
+		pumper.SetOutput(p.Output)
+		return cmd, newTimedInput(&ttyInput{tty}), pumper, nil
+	}
+
+	// getting stdin pipe, the process reads /dev/null unless it accepts the input
+	var stdin io.WriteCloser
+	if p.Stdin {
+		pipe, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		stdin = newTimedInput(pipe)
+	}
+
+	// getting stdout pipe
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// getting stderr pipe
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// starting a new process
+	releaseLimited, err := limitCommand(cmd, p.Limits)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	err = cmd.Start()
+	if limitErr := releaseLimited(); err == nil && limitErr != nil {
+		cmd.Wait()
+		return nil, nil, nil, limitErr
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	pumper := NewPumper(stdout, stderr)
+	pumper.SetOutput(p.Output)
+	return cmd, stdin, pumper, nil
+}
+
+// Kills the started native process which is not going to be published and releases its output.
+func abandonCommand(cmd *exec.Cmd, pumper *LogsPumper) {
+	if err := signalGroup(cmd.Process.Pid, syscall.SIGKILL); err != nil {
+		log.Printf("Couldn't kill abandoned process '%d'. %s", cmd.Process.Pid, err)
+	}
+	cmd.Wait()
+	if pumper.tty != nil {
+		closeFile(pumper.tty)
+	}
+}
+
 // Get retrieves process by pid.
 // If process doesn't exist then error of type NoProcessError is returned.
 func Get(pid uint64) (MachineProcess, error) {
@@ -267,41 +623,138 @@ func Get(pid uint64) (MachineProcess, error) {
 
 }
 
-// GetProcesses retrieves list of processes.
-// If parameter all is true then returns all processes,
-// otherwise returns only live processes.
-func GetProcesses(all bool) []MachineProcess {
+// GetProcesses retrieves list of processes matched by the filter,
+// sorted and paged according to the filter.
+func GetProcesses(filter Filter) []MachineProcess {
 	processes.RLock()
 	defer processes.RUnlock()
 
 	pArr := make([]MachineProcess, 0, len(processes.items))
 	for _, p := range processes.items {
-		if all {
+		p.mutex.RLock()
+		if filter.matches(p) {
 			pArr = append(pArr, *p)
-		} else {
-			p.mutex.RLock()
-			if p.Alive {
-				pArr = append(pArr, *p)
-			}
-			p.mutex.RUnlock()
 		}
+		p.mutex.RUnlock()
 	}
-	return pArr
+	return filter.apply(pArr)
 }
 
 // Kill kills process by given pid.
 // Returns an error when any error occurs during process kill.
 // If process doesn't exist error of type NoProcessError is returned.
 func Kill(pid uint64) error {
+	p, err := aliveProcess(pid)
+	if err != nil {
+		return err
+	}
+	nativePid, running := p.stop()
+	if !running {
+		return nil
+	}
+	return signalGroup(nativePid, syscall.SIGKILL)
+}
+
+// KillGracefully sends SIGTERM to the process group and if the process
+// is still alive after the grace period, kills it with SIGKILL.
+// The function doesn't wait for the grace period to elapse.
+// If process doesn't exist error of type NoProcessError is returned.
+func KillGracefully(pid uint64, gracePeriod time.Duration) error {
+	p, err := aliveProcess(pid)
+	if err != nil {
+		return err
+	}
+	nativePid, running := p.stop()
+	if !running {
+		return nil
+	}
+	if err := signalGroup(nativePid, syscall.SIGTERM); err != nil {
+		return err
+	}
+	time.AfterFunc(gracePeriod, func() {
+		// the native process which is already waited may have its pid reused
+		if running, ok := p.runningNativePid(); ok && running == nativePid {
+			if err := signalGroup(nativePid, syscall.SIGKILL); err != nil {
+				log.Printf("Couldn't kill process '%d' after grace period. %s", pid, err)
+			}
+		}
+	})
+	return nil
+}
+
+// Signal sends given signal to the process group.
+// If process doesn't exist error of type NoProcessError is returned.
+func Signal(pid uint64, sig syscall.Signal) error {
+	p, err := aliveProcess(pid)
+	if err != nil {
+		return err
+	}
+	nativePid, ok := p.runningNativePid()
+	if !ok {
+		return notRunning(pid)
+	}
+	return signalGroup(nativePid, sig)
+}
+
+// Returns the pid of the native process and true if the native process is running,
+// or false if it has already exited e.g. the process waits for restart,
+// as the pid of the exited native process may be reused by another one.
+func (process *MachineProcess) runningNativePid() (int, bool) {
+	process.mutex.RLock()
+	defer process.mutex.RUnlock()
+	if process.command == nil {
+		return 0, false
+	}
+	return process.NativePid, true
+}
+
+// Sends the signal to the process group of the native process, see https://github.com/golang/go/issues/8854
+// The group is created by setsid right after the native process starts,
+// until then the native process is the only one to signal.
+func signalGroup(nativePid int, sig syscall.Signal) error {
+	err := syscall.Kill(-nativePid, sig)
+	if err == syscall.ESRCH {
+		return syscall.Kill(nativePid, sig)
+	}
+	return err
+}
+
+// WriteInput writes given data to the stdin of the process.
+// If process doesn't exist error of type NoProcessError is returned,
+// if process is not alive error of type NotAliveError is returned,
+// a regular error is returned if process input is already closed.
+func WriteInput(pid uint64, data []byte) error {
+	stdin, err := aliveStdin(pid)
+	if err != nil {
+		return err
+	}
+	_, err = stdin.Write(data)
+	return err
+}
+
+// CloseInput closes the stdin of the process, so the process reads EOF.
+// If process doesn't exist error of type NoProcessError is returned,
+// if process is not alive error of type NotAliveError is returned,
+// a regular error is returned if process input is already closed.
+func CloseInput(pid uint64) error {
 	p, ok := directGet(pid)
 	if !ok {
 		return noProcess(pid)
 	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
 	if !p.Alive {
 		return notAlive(pid)
 	}
-	// workaround for killing child processes see https://github.com/golang/go/issues/8854
-	return syscall.Kill(-p.NativePid, syscall.SIGKILL)
+	if !p.Stdin && !p.Tty {
+		return fmt.Errorf("Process with id '%d' is started without input", pid)
+	}
+	if p.stdin == nil {
+		return inputClosed(pid)
+	}
+	stdin := p.stdin
+	p.stdin = nil
+	return stdin.Close()
 }
 
 // ReadLogs reads process logs between [from, till] inclusive.
@@ -313,14 +766,28 @@ func ReadLogs(pid uint64, from time.Time, till time.Time) ([]*LogMessage, error)
 		return nil, noProcess(pid)
 	}
 
-	p.mutex.RLock()
-	reader, err := newLogsReader(p, from, till)
-	p.mutex.RUnlock()
+	logs, err := p.logStore()
+	if err != nil {
+		return nil, err
+	}
+	return logs.Read(pid, from, till)
+}
 
+// ReadLogsTail reads at most n latest process logs between [from, till] inclusive,
+// the logs file is read from its end, so earlier logs are not decoded.
+// Returns an error if any error occurs during logs reading.
+// If process doesn't exist error of type NoProcessError is returned.
+func ReadLogsTail(pid uint64, from time.Time, till time.Time, n int) ([]*LogMessage, error) {
+	p, ok := directGet(pid)
+	if !ok {
+		return nil, noProcess(pid)
+	}
+
+	logs, err := p.logStore()
 	if err != nil {
 		return nil, err
 	}
-	return reader.ReadLogs()
+	return logs.ReadTail(pid, from, till, n)
 }
 
 // ReadAllLogs reads all process logs.
@@ -392,9 +859,9 @@ func RestoreSubscriber(pid uint64, subscriber Subscriber, after time.Time) error
 
 	// Read logs between after and now
 	var logs []*LogMessage
-	reader, err := newLogsReader(p, after, time.Now())
-	if err == nil {
-		if logs, err = reader.ReadLogs(); err != nil {
+	if p.logs != nil {
+		var err error
+		if logs, err = p.logs.Read(pid, after, time.Now()); err != nil {
 			return err
 		}
 	}
@@ -417,9 +884,9 @@ func RestoreSubscriber(pid uint64, subscriber Subscriber, after time.Time) error
 		message := logs[i]
 		if message.Time.After(after) {
 			if message.Kind == StdoutKind {
-				subscriber.Consumer.Accept(newStdoutEvent(p.Pid, message.Text, message.Time))
+				subscriber.Consumer.Accept(newStdoutEvent(p.Pid, message.Text, message.Mode, message.Time))
 			} else {
-				subscriber.Consumer.Accept(newStderrEvent(p.Pid, message.Text, message.Time))
+				subscriber.Consumer.Accept(newStderrEvent(p.Pid, message.Text, message.Mode, message.Time))
 			}
 		}
 	}
@@ -457,36 +924,73 @@ func UpdateSubscriber(pid uint64, id string, newMask uint64) error {
 
 // OnStdout notifies subscribers about new output in stdout.
 func (process *MachineProcess) OnStdout(line string, time time.Time) {
-	process.notifySubs(newStdoutEvent(process.Pid, line, time), StdoutBit)
+	process.notifySubs(newStdoutEvent(process.Pid, line, outputMode(process.Output), time), StdoutBit)
+	if process.readiness != nil {
+		process.readiness.checkLine(line)
+	}
 }
 
 // OnStderr notifies subscribers about new output in stderr.
 func (process *MachineProcess) OnStderr(line string, time time.Time) {
-	process.notifySubs(newStderrEvent(process.Pid, line, time), StderrBit)
+	process.notifySubs(newStderrEvent(process.Pid, line, outputMode(process.Output), time), StderrBit)
+	if process.readiness != nil {
+		process.readiness.checkLine(line)
+	}
 }
 
 // Close cleanups process resources and notifies subscribers about process death.
 func (process *MachineProcess) Close() {
 	// Cleanup command resources
 	exitCode := 0
+	signal := ""
 	if err := process.command.Wait(); err != nil {
 		if exiterr, ok := err.(*exec.ExitError); ok {
 			status := exiterr.Sys().(syscall.WaitStatus)
 			exitCode = status.ExitStatus()
+			if status.Signaled() {
+				signal = SignalName(status.Signal())
+			}
 		} else {
 			log.Printf("Error occurs on process cleanup. %s", err)
 		}
 	}
+	process.mutex.Lock()
+	// the native process is waited, so it must not be signaled anymore
+	process.command = nil
+	process.oomKilled = releaseLimits(process.NativePid, process.Limits)
+	process.mutex.Unlock()
+	if process.scheduleRestart(exitCode, signal) {
+		return
+	}
+	process.die(exitCode, signal)
+}
+
+// Marks the process as dead and notifies subscribers about process death.
+func (process *MachineProcess) die(exitCode int, signal string) {
 	// Cleanup machine process resources before dead event is sent
 	process.mutex.Lock()
+	if process.timeoutTimer != nil {
+		process.timeoutTimer.Stop()
+		process.timeoutTimer = nil
+	}
 	process.Alive = false
 	process.deathTime = time.Now()
 	process.command = nil
 	process.pumper = nil
-	process.fileLogger = nil
+	process.stdin = nil
+	if process.logs != nil {
+		process.logs.Close(process.Pid)
+	}
 	process.ExitCode = exitCode
+	process.Signal = signal
+	process.Reason = deathReason(signal, process.timedOut, process.oomKilled)
+	close(process.done)
 	process.mutex.Unlock()
+	persistProcesses()
 
+	if process.readiness != nil {
+		process.readiness.stop()
+	}
 	process.notifySubs(newDiedEvent(*process), StatusBit)
 
 	process.mutex.Lock()
@@ -494,6 +998,38 @@ func (process *MachineProcess) Close() {
 	process.mutex.Unlock()
 }
 
+// Gracefully terminates the process as its timeout elapsed.
+func (process *MachineProcess) onTimeout() {
+	process.mutex.Lock()
+	if !process.Alive {
+		process.mutex.Unlock()
+		return
+	}
+	process.timedOut = true
+	process.mutex.Unlock()
+
+	log.Printf("Process '%d' timed out after %ds, terminating it", process.Pid, process.Timeout)
+	if err := KillGracefully(process.Pid, DefaultKillGracePeriod); err != nil {
+		log.Printf("Couldn't terminate timed out process '%d'. %s", process.Pid, err)
+	}
+}
+
+// Figures out why the process died by the signal which terminated it.
+func deathReason(signal string, timedOut bool, oomKilled bool) string {
+	switch {
+	case timedOut:
+		return TimeoutReason
+	case oomKilled:
+		return OOMReason
+	case signal == SignalName(syscall.SIGKILL):
+		return KilledReason
+	case signal != "":
+		return SignaledReason
+	default:
+		return ExitedReason
+	}
+}
+
 func (process *MachineProcess) notifySubs(event Event, typeBit uint64) {
 	process.mutex.RLock()
 	subs := process.subs
@@ -520,6 +1056,42 @@ func tryAccept(consumer EventConsumer, event Event) (ok bool) {
 	return true
 }
 
+// Returns stdin of the process, the stdin is returned
+// without holding the lock as writing to it may take time.
+func aliveStdin(pid uint64) (io.WriteCloser, error) {
+	p, ok := directGet(pid)
+	if !ok {
+		return nil, noProcess(pid)
+	}
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	if !p.Alive {
+		return nil, notAlive(pid)
+	}
+	if !p.Stdin && !p.Tty {
+		return nil, fmt.Errorf("Process with id '%d' is started without input", pid)
+	}
+	if p.stdin == nil {
+		return nil, inputClosed(pid)
+	}
+	return p.stdin, nil
+}
+
+// Returns the process with given pid if it exists and alive,
+// otherwise returns either NoProcessError or NotAliveError.
+func aliveProcess(pid uint64) (*MachineProcess, error) {
+	p, ok := directGet(pid)
+	if !ok {
+		return nil, noProcess(pid)
+	}
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	if !p.Alive {
+		return nil, notAlive(pid)
+	}
+	return p, nil
+}
+
 func directGet(pid uint64) (*MachineProcess, bool) {
 	processes.RLock()
 	defer processes.RUnlock()
@@ -527,36 +1099,43 @@ func directGet(pid uint64) (*MachineProcess, bool) {
 	return item, ok
 }
 
-// Creates a new logs reader for given process.
-func newLogsReader(p *MachineProcess, from time.Time, till time.Time) (*LogsReader, error) {
-	if p.logfileName == "" {
-		return nil, fmt.Errorf("Logs file for process '%d' is missing", p.Pid)
+// Computes the environment of the command which starts given process,
+// user environment overrides the agent's one and is overridden by the process Env.
+// Returns nil if the process should inherit the agent's environment as is.
+func envOf(p MachineProcess, userEnv map[string]string) []string {
+	if len(p.Env) == 0 && len(userEnv) == 0 && !p.CleanEnv {
+		return nil
 	}
-	if p.Alive {
-		p.fileLogger.Flush()
+	env := []string{}
+	if !p.CleanEnv {
+		env = append(env, os.Environ()...)
+		env = appendEnv(env, userEnv)
 	}
-	return NewLogsReader(p.logfileName).From(from).Till(till), nil
+	return appendEnv(env, p.Env)
 }
 
-// Creates a new file logger for given pid.
-func newFileLogger(pid uint64) (*FileLogger, error) {
-	if logsDir == "" {
-		return nil, nil
+// Appends variables to the environment in the order of their names,
+// exec.Cmd keeps the last value of duplicated variables.
+func appendEnv(env []string, vars map[string]string) []string {
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
 	}
-
-	// Figure out the place for logs file
-	dir, err := logsDistributor.DirForPid(logsDir, pid)
-	if err != nil {
-		return nil, err
+	sort.Strings(keys)
+	for _, k := range keys {
+		env = append(env, k+"="+vars[k])
 	}
+	return env
+}
 
-	// TODO extract file process name generation to the strategy interface(consider LogsDistributor)
-	filename := fmt.Sprintf("%s%cpid-%d", dir, os.PathSeparator, pid)
-	fileLogger, err := NewLogger(filename)
-	if err != nil {
-		return nil, err
+// Returns the store the process logs are kept in, the process must not be locked.
+func (process *MachineProcess) logStore() (LogStore, error) {
+	process.mutex.RLock()
+	defer process.mutex.RUnlock()
+	if process.logs == nil {
+		return nil, fmt.Errorf("Logs of process '%d' are missing", process.Pid)
 	}
-	return fileLogger, nil
+	return process.logs, nil
 }
 
 // Returns an error indicating that process with given pid doesn't exist.
@@ -567,6 +1146,17 @@ func noProcess(pid uint64) *NoProcessError {
 	}
 }
 
+// Returns an error indicating that input of the process with given pid is closed
+func inputClosed(pid uint64) error {
+	return fmt.Errorf("Input of the process with id '%d' is closed", pid)
+}
+
+// Returns an error indicating that the native process of the process
+// with given pid is not running, as the process waits for restart
+func notRunning(pid uint64) error {
+	return fmt.Errorf("Process with id '%d' is waiting for restart", pid)
+}
+
 // Returns an error indicating that process with given pid is not alive
 func notAlive(pid uint64) *NotAliveError {
 	return &NotAliveError{
@@ -574,3 +1164,11 @@ func notAlive(pid uint64) *NotAliveError {
 		Pid:   pid,
 	}
 }
+
+// Returns an error indicating that process with given pid is still alive.
+func stillAlive(pid uint64) *AliveError {
+	return &AliveError{
+		error: fmt.Errorf("Process with id '%d' is alive", pid),
+		Pid:   pid,
+	}
+}
@@ -35,6 +35,18 @@ func (pb *Builder) CmdLine(cmdLine string) *Builder {
 	return pb
 }
 
+// CmdInterpreter sets the name of the interpreter which executes command line of process.
+func (pb *Builder) CmdInterpreter(interpreter string) *Builder {
+	pb.command.Interpreter = interpreter
+	return pb
+}
+
+// CmdArgv sets the program and its arguments process executes without interpreter.
+func (pb *Builder) CmdArgv(argv ...string) *Builder {
+	pb.command.Argv = argv
+	return pb
+}
+
 // CmdType sets type of command that creates a process.
 func (pb *Builder) CmdType(cmdType string) *Builder {
 	pb.command.Type = cmdType
@@ -47,6 +59,68 @@ func (pb *Builder) CmdName(cmdName string) *Builder {
 	return pb
 }
 
+// CmdEnv sets environment variables of process.
+func (pb *Builder) CmdEnv(env map[string]string) *Builder {
+	pb.command.Env = env
+	return pb
+}
+
+// CmdWorkingDir sets the directory process is executed in.
+func (pb *Builder) CmdWorkingDir(dir string) *Builder {
+	pb.command.WorkingDir = dir
+	return pb
+}
+
+// CmdTimeout sets the time in seconds after which the process is terminated.
+func (pb *Builder) CmdTimeout(timeout int) *Builder {
+	pb.command.Timeout = timeout
+	return pb
+}
+
+// CmdLimits sets the resources available to the process.
+func (pb *Builder) CmdLimits(limits *Limits) *Builder {
+	pb.command.Limits = limits
+	return pb
+}
+
+// CmdOutput sets how the output of the process is captured.
+func (pb *Builder) CmdOutput(output *OutputOptions) *Builder {
+	pb.command.Output = output
+	return pb
+}
+
+// CmdStdin makes the input of process writable, otherwise process reads /dev/null.
+func (pb *Builder) CmdStdin() *Builder {
+	pb.command.Stdin = true
+	return pb
+}
+
+// CmdTty makes the process run under a pseudo-terminal of the given window size.
+func (pb *Builder) CmdTty(cols int, rows int) *Builder {
+	pb.command.Tty = true
+	pb.command.Cols = cols
+	pb.command.Rows = rows
+	return pb
+}
+
+// CmdStripAnsi makes escape sequences to be stripped from the process logs.
+func (pb *Builder) CmdStripAnsi() *Builder {
+	pb.command.StripAnsi = true
+	return pb
+}
+
+// CmdUser sets the user the process runs as.
+func (pb *Builder) CmdUser(user string) *Builder {
+	pb.command.User = user
+	return pb
+}
+
+// CmdLabels sets labels of the process.
+func (pb *Builder) CmdLabels(labels map[string]string) *Builder {
+	pb.command.Labels = labels
+	return pb
+}
+
 // BeforeEventsHook sets the hook which will be called once before
 // process subscribers notified with any of the process events,
 // and after process is started.
@@ -76,6 +150,25 @@ func (pb *Builder) Build() MachineProcess {
 		Name:             pb.command.Name,
 		CommandLine:      pb.command.CommandLine,
 		Type:             pb.command.Type,
+		Interpreter:      pb.command.Interpreter,
+		Argv:             pb.command.Argv,
+		Env:              pb.command.Env,
+		CleanEnv:         pb.command.CleanEnv,
+		WorkingDir:       pb.command.WorkingDir,
+		Stdin:            pb.command.Stdin,
+		Timeout:          pb.command.Timeout,
+		RestartPolicy:    pb.command.RestartPolicy,
+		MaxRetries:       pb.command.MaxRetries,
+		RestartBackoff:   pb.command.RestartBackoff,
+		Readiness:        pb.command.Readiness,
+		Limits:           pb.command.Limits,
+		Output:           pb.command.Output,
+		Tty:              pb.command.Tty,
+		Cols:             pb.command.Cols,
+		Rows:             pb.command.Rows,
+		StripAnsi:        pb.command.StripAnsi,
+		User:             pb.command.User,
+		Labels:           pb.command.Labels,
 		beforeEventsHook: pb.beforeEventsHook,
 		subs:             pb.subscribers,
 	}
@@ -14,20 +14,37 @@ package process
 import (
 	"log"
 	"os"
+	"sort"
 	"time"
 )
 
-// Cleaner cleanups processes that died
+// KeepLabel marks the process which is never removed by the cleaner,
+// the process is kept regardless of the label value.
+const KeepLabel = "keep"
+
+// Cleaner cleanups processes that died.
+// Processes labeled with KeepLabel are never cleaned up.
 type Cleaner struct {
-	CleanupPeriod    time.Duration
+	CleanupPeriod time.Duration
+
+	// Dead processes which died more than this time ago are removed,
+	// negative value disables the cleanup by the death time.
 	CleanupThreshold time.Duration
+
+	// The max number of dead processes, the earliest died processes are removed first.
+	// 0 means no limit.
+	MaxDeadProcesses int
+
+	// The max size in bytes of the logs of all the processes, the logs are freed
+	// by removing the earliest died processes first. 0 means no limit.
+	MaxLogsSize int64
 }
 
 // NewCleaner create new instance of Cleaner
 func NewCleaner(period int, threshold int) *Cleaner {
 	return &Cleaner{
-		time.Duration(period) * time.Minute,
-		time.Duration(threshold) * time.Minute,
+		CleanupPeriod:    time.Duration(period) * time.Minute,
+		CleanupThreshold: time.Duration(threshold) * time.Minute,
 	}
 }
 
@@ -54,20 +71,106 @@ func (pc *Cleaner) CleanPeriodically() {
 //                process1          process2
 //
 // the method execution will remove the process1.
+// Then if there are more than MaxDeadProcesses dead processes or logs
+// take more than MaxLogsSize bytes the earliest died processes are removed.
+// The chains which finished before the death bound are removed if their step processes are removed.
 func (pc *Cleaner) CleanOnce() {
 	deathBound := time.Now().Add(-pc.CleanupThreshold)
 	processes.Lock()
+
+	// collect dead processes which may be removed
+	deadCount := 0
+	candidates := make([]cleanupCandidate, 0)
+	var totalLogsSize int64
 	for _, mp := range processes.items {
 		mp.mutex.RLock()
-		if !mp.Alive && mp.deathTime.Before(deathBound) {
-			delete(processes.items, mp.Pid)
-			if err := os.Remove(mp.logfileName); err != nil {
-				if !os.IsNotExist(err) {
-					log.Printf("Couldn't remove process logs file, '%s'", mp.logfileName)
-				}
+		var size int64
+		if pc.MaxLogsSize > 0 && mp.logs != nil {
+			size = mp.logs.Size(mp.Pid)
+			totalLogsSize += size
+		}
+		if !mp.Alive {
+			deadCount++
+			if _, keep := mp.Labels[KeepLabel]; !keep {
+				candidates = append(candidates, cleanupCandidate{mp, mp.deathTime, size})
 			}
 		}
 		mp.mutex.RUnlock()
 	}
+	sort.Sort(byDeathTime(candidates))
+
+	for _, c := range candidates {
+		expired := pc.CleanupThreshold >= 0 && c.deathTime.Before(deathBound)
+		tooMany := pc.MaxDeadProcesses > 0 && deadCount > pc.MaxDeadProcesses
+		tooBig := pc.MaxLogsSize > 0 && totalLogsSize > pc.MaxLogsSize
+		if !expired && !tooMany && !tooBig {
+			continue
+		}
+		deadCount--
+		totalLogsSize -= c.logsSize
+		c.mp.mutex.RLock()
+		removeProcess(c.mp)
+		c.mp.mutex.RUnlock()
+	}
+
+	// the chains which step processes are removed, or didn't start, expire as dead processes
+	if pc.CleanupThreshold >= 0 {
+		removeChainsFinishedBefore(deathBound)
+	}
+	processes.Unlock()
+	persistProcesses()
+}
+
+// Forget removes the dead process along with its logs.
+// If process doesn't exist error of type NoProcessError is returned,
+// if process is alive error of type AliveError is returned.
+func Forget(pid uint64) error {
+	processes.Lock()
+	mp, ok := processes.items[pid]
+	if !ok {
+		processes.Unlock()
+		return noProcess(pid)
+	}
+	mp.mutex.RLock()
+	if mp.Alive {
+		mp.mutex.RUnlock()
+		processes.Unlock()
+		return stillAlive(pid)
+	}
+	removeProcess(mp)
+	mp.mutex.RUnlock()
 	processes.Unlock()
+	persistProcesses()
+	return nil
+}
+
+// The dead process which may be removed by the cleaner.
+type cleanupCandidate struct {
+	mp        *MachineProcess
+	deathTime time.Time
+	logsSize  int64
+}
+
+// Sorts cleanup candidates from the earliest died.
+type byDeathTime []cleanupCandidate
+
+func (c byDeathTime) Len() int           { return len(c) }
+func (c byDeathTime) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+func (c byDeathTime) Less(i, j int) bool { return c[i].deathTime.Before(c[j].deathTime) }
+
+// Removes the dead process and its logs, processes and the process must be locked.
+// The chain of the process is removed along with its last step process.
+func removeProcess(mp *MachineProcess) {
+	delete(processes.items, mp.Pid)
+	if mp.ParentChain != 0 {
+		removeChainIfForgotten(mp.ParentChain)
+	}
+	if mp.logs == nil {
+		return
+	}
+	if err := mp.logs.Delete(mp.Pid); err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Couldn't remove logs of process '%d'. %s", mp.Pid, err)
+		}
+	}
 }
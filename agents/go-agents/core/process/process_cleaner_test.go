@@ -12,6 +12,7 @@
 package process
 
 import (
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -57,6 +58,84 @@ func TestCleansOnlyExpiredProcesses(t *testing.T) {
 	processMustExist(p4.Pid, t)
 }
 
+func TestCleansEarliestDiedProcessesOverMaxCount(t *testing.T) {
+	resetProcesses()
+	p1 := &MachineProcess{Pid: 1, Alive: false, mutex: &sync.RWMutex{}, deathTime: time.Now().Add(-time.Hour * 3)}
+	p2 := &MachineProcess{Pid: 2, Alive: false, mutex: &sync.RWMutex{}, deathTime: time.Now().Add(-time.Hour * 2), Labels: map[string]string{KeepLabel: ""}}
+	p3 := &MachineProcess{Pid: 3, Alive: false, mutex: &sync.RWMutex{}, deathTime: time.Now().Add(-time.Hour)}
+	p4 := &MachineProcess{Pid: 4, Alive: false, mutex: &sync.RWMutex{}, deathTime: time.Now()}
+	p5 := &MachineProcess{Pid: 5, Alive: true, mutex: &sync.RWMutex{}}
+	putProcesses(p1, p2, p3, p4, p5)
+
+	(&Cleaner{CleanupThreshold: -1, MaxDeadProcesses: 2}).CleanOnce()
+
+	processMustNotExist(p1.Pid, t)
+	processMustExist(p2.Pid, t)
+	processMustNotExist(p3.Pid, t)
+	processMustExist(p4.Pid, t)
+	processMustExist(p5.Pid, t)
+}
+
+func TestCleansEarliestDiedProcessesOverMaxLogsSize(t *testing.T) {
+	resetProcesses()
+	p1 := &MachineProcess{Pid: 1, Alive: false, mutex: &sync.RWMutex{}, deathTime: time.Now().Add(-time.Hour * 3)}
+	p2 := &MachineProcess{Pid: 2, Alive: false, mutex: &sync.RWMutex{}, deathTime: time.Now().Add(-time.Hour * 2)}
+	p3 := &MachineProcess{Pid: 3, Alive: false, mutex: &sync.RWMutex{}, deathTime: time.Now().Add(-time.Hour)}
+	p4 := &MachineProcess{Pid: 4, Alive: true, mutex: &sync.RWMutex{}}
+	store := NewMemoryLogStore(0)
+	for _, p := range []*MachineProcess{p1, p2, p3, p4} {
+		p.logs = store
+		store.Create(p.Pid)
+		store.Append(p.Pid, &LogMessage{Kind: StdoutKind, Time: time.Now(), Text: strings.Repeat("x", 100)})
+	}
+	putProcesses(p1, p2, p3, p4)
+
+	(&Cleaner{CleanupThreshold: -1, MaxLogsSize: 250}).CleanOnce()
+
+	processMustNotExist(p1.Pid, t)
+	processMustNotExist(p2.Pid, t)
+	processMustExist(p3.Pid, t)
+	processMustExist(p4.Pid, t)
+	if _, err := store.Read(p1.Pid, time.Time{}, time.Now()); err == nil {
+		t.Fatalf("Expected logs of process '%d' to be removed", p1.Pid)
+	}
+}
+
+func TestForgetsOnlyDeadProcess(t *testing.T) {
+	resetProcesses()
+	p1 := &MachineProcess{Pid: 1, Alive: false, mutex: &sync.RWMutex{}, deathTime: time.Now(), Labels: map[string]string{KeepLabel: ""}}
+	p2 := &MachineProcess{Pid: 2, Alive: true, mutex: &sync.RWMutex{}}
+	putProcesses(p1, p2)
+
+	if err := Forget(p1.Pid); err != nil {
+		t.Fatal(err)
+	}
+	processMustNotExist(p1.Pid, t)
+
+	if _, ok := Forget(p2.Pid).(*AliveError); !ok {
+		t.Fatal("Expected alive process not to be forgotten")
+	}
+	processMustExist(p2.Pid, t)
+
+	if _, ok := Forget(p1.Pid).(*NoProcessError); !ok {
+		t.Fatal("Expected forgetting of not existing process to fail")
+	}
+}
+
+func resetProcesses() {
+	processes.Lock()
+	processes.items = make(map[uint64]*MachineProcess)
+	processes.Unlock()
+}
+
+func putProcesses(items ...*MachineProcess) {
+	processes.Lock()
+	for _, p := range items {
+		processes.items[p.Pid] = p
+	}
+	processes.Unlock()
+}
+
 func processMustNotExist(pid uint64, t *testing.T) {
 	_, err := Get(pid)
 	if err == nil {
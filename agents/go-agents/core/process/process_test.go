@@ -15,11 +15,14 @@ import (
 	"log"
 	"math/rand"
 	"os"
+	"os/user"
+	"regexp"
+	"strconv"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 
-	"fmt"
 	"github.com/eclipse/che/agents/go-agents/core/process"
 	"github.com/eclipse/che/agents/go-agents/core/process/processtest"
 )
@@ -30,6 +33,12 @@ const (
 
 var alphabet = []byte("abcdefgh123456789")
 
+func TestMain(m *testing.M) {
+	// the test binary is started as the limits helper of the limited processes
+	process.RunLimitsHelper()
+	os.Exit(m.Run())
+}
+
 func TestOneLineOutput(t *testing.T) {
 	defer wipeLogs()
 	// create and start a process
@@ -192,17 +201,29 @@ func TestReadProcessLogs(t *testing.T) {
 	}
 }
 
-func TestLogsAreNotWrittenIfLogsDirIsNotSet(t *testing.T) {
+func TestLogsAreKeptInMemoryIfLogsDirIsNotSet(t *testing.T) {
 	p := doStartAndWaitTestProcess(testCmd, "", processtest.NewEventsCaptor(process.DiedEventType), t)
 
-	_, err := process.ReadAllLogs(p.Pid)
-	if err == nil {
-		t.Fatal("Error must be returned in the case when the process doesn't write logs")
+	logs, err := process.ReadAllLogs(p.Pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 10 || logs[0].Text != "1" || logs[9].Text != "10" {
+		t.Fatalf("Expected to read 10 logs from memory but got %v", logs)
 	}
 
-	expected := fmt.Sprintf("Logs file for process '%d' is missing", p.Pid)
-	if err.Error() != expected {
-		t.Fatalf("Expected to get '%s' error but got '%s'", err.Error(), expected)
+	// logs and died event are published synchronously as the process is dead
+	channel := make(chan process.Event, 11)
+	err = process.RestoreSubscriber(p.Pid, process.Subscriber{
+		ID:       "test",
+		Mask:     process.DefaultMask,
+		Consumer: &channelEventConsumer{channel: channel},
+	}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(channel) != 11 {
+		t.Fatalf("Expected to restore 10 output events and died event but got %d events", len(channel))
 	}
 }
 
@@ -232,6 +253,8 @@ func TestProcessExitCodeIs0IfFinishedOk(t *testing.T) {
 		t.Fatalf("Expected last captured event to be process died event, but it is %s", diedEvent.Type())
 	} else if diedEvent.ExitCode != 0 {
 		t.Fatalf("Expected process died event exit code to be 0, but it is %d", diedEvent.ExitCode)
+	} else if diedEvent.Reason != process.ExitedReason {
+		t.Fatalf("Expected process died event reason to be '%s', but it is '%s'", process.ExitedReason, diedEvent.Reason)
 	}
 }
 
@@ -252,6 +275,1118 @@ func TestProcessExitCodeIsNot0IfFinishedNotOk(t *testing.T) {
 	}
 }
 
+func TestProcessIsStartedWithEnvAndWorkingDir(t *testing.T) {
+	dir := os.TempDir()
+	captor := processtest.NewEventsCaptor(process.DiedEventType)
+	captor.Capture()
+
+	pb := process.NewBuilder()
+	pb.CmdName("test")
+	pb.CmdLine("echo $TEST_VAR && pwd")
+	pb.CmdEnv(map[string]string{"TEST_VAR": "test-value"})
+	pb.CmdWorkingDir(dir)
+	pb.SubscribeDefault("events-captor", captor)
+
+	p, err := pb.Start()
+	if err != nil {
+		captor.Stop()
+		t.Fatal(err)
+	}
+	if ok := <-captor.Wait(time.Second * 2); !ok {
+		t.Fatalf("Process %d doesn't finish its execution in 2 seconds", p.Pid)
+	}
+	if p.WorkingDir != dir || p.Env["TEST_VAR"] != "test-value" {
+		t.Fatalf("Expected process to keep env and working dir, but got %v", p)
+	}
+
+	events := captor.Events()
+	started := events[0].(*process.StartedEvent)
+	if started.WorkingDir != dir || started.Env["TEST_VAR"] != "test-value" {
+		t.Fatalf("Expected started event to contain env and working dir, but got %v", started)
+	}
+	expected := []string{"test-value", dir}
+	for idx, line := range expected {
+		out := events[idx+1].(*process.OutputEvent)
+		if out.Text != line {
+			t.Fatalf("Expected output line '%s' but got '%s'", line, out.Text)
+		}
+	}
+}
+
+func TestProcessIsStartedWithCleanEnv(t *testing.T) {
+	captor := processtest.NewEventsCaptor(process.DiedEventType)
+	captor.Capture()
+
+	command := process.Command{
+		Name:        "test",
+		CommandLine: "echo \"$HOME:$TEST_VAR\"",
+		Env:         map[string]string{"TEST_VAR": "test-value"},
+		CleanEnv:    true,
+	}
+	pb := process.NewBuilder().Cmd(command).SubscribeDefault("events-captor", captor)
+	if _, err := pb.Start(); err != nil {
+		captor.Stop()
+		t.Fatal(err)
+	}
+	if ok := <-captor.Wait(time.Second * 2); !ok {
+		t.Fatal("Process doesn't finish its execution in 2 seconds")
+	}
+
+	out := captor.Events()[1].(*process.OutputEvent)
+	if out.Text != ":test-value" {
+		t.Fatalf("Expected output ':test-value' but got '%s'", out.Text)
+	}
+}
+
+func TestWriteInputAndCloseInput(t *testing.T) {
+	captor := processtest.NewEventsCaptor(process.DiedEventType)
+	captor.Capture()
+
+	p, err := process.NewBuilder().CmdName("test").CmdLine("cat").CmdStdin().SubscribeDefault("events-captor", captor).Start()
+	if err != nil {
+		captor.Stop()
+		t.Fatal(err)
+	}
+	if err := process.WriteInput(p.Pid, []byte("line1\nline2\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := process.CloseInput(p.Pid); err != nil {
+		t.Fatal(err)
+	}
+	if err := process.WriteInput(p.Pid, []byte("line3\n")); err == nil {
+		t.Fatal("Expected error when writing to the closed input")
+	}
+	if ok := <-captor.Wait(time.Second * 2); !ok {
+		process.Kill(p.Pid)
+		t.Fatal("Process doesn't finish its execution in 2 seconds after input is closed")
+	}
+
+	checkEventsOrder(t, captor.Events(),
+		process.StartedEventType,
+		process.StdoutEventType,
+		process.StdoutEventType,
+		process.DiedEventType)
+	if err := process.WriteInput(p.Pid, []byte("line4\n")); err == nil {
+		t.Fatal("Expected error when writing to the input of dead process")
+	} else if _, ok := err.(*process.NotAliveError); !ok {
+		t.Fatalf("Expected error of type NotAliveError, but got '%s'", err)
+	}
+}
+
+func TestProcessReadsEmptyInputByDefault(t *testing.T) {
+	captor := processtest.NewEventsCaptor(process.DiedEventType)
+	captor.Capture()
+
+	p, err := process.NewBuilder().CmdName("test").CmdLine("cat").SubscribeDefault("events-captor", captor).Start()
+	if err != nil {
+		captor.Stop()
+		t.Fatal(err)
+	}
+	if ok := <-captor.Wait(time.Second * 2); !ok {
+		process.Kill(p.Pid)
+		t.Fatal("Expected process to read EOF from its input")
+	}
+	if err := process.WriteInput(p.Pid, []byte("line\n")); err == nil {
+		t.Fatal("Expected error when writing to the input of the process started without input")
+	}
+}
+
+func TestWriteInputFailsIfProcessDoesNotReadIt(t *testing.T) {
+	process.SetInputWriteTimeout(100 * time.Millisecond)
+	defer process.SetInputWriteTimeout(process.DefaultInputWriteTimeout)
+
+	p, err := process.NewBuilder().CmdName("test").CmdLine("sleep 10").CmdStdin().Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer process.Kill(p.Pid)
+
+	// the data is larger than the pipe buffer, so the write blocks
+	data := make([]byte, 1024*1024)
+	start := time.Now()
+	if err := process.WriteInput(p.Pid, data); err == nil {
+		t.Fatal("Expected error when the process doesn't read its input")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected write to fail after the timeout, but it took %s", elapsed)
+	}
+	if err := process.WriteInput(p.Pid, []byte("x")); err == nil {
+		t.Fatal("Expected error while the previous input is still being written")
+	}
+}
+
+func TestSignalTerminatesProcess(t *testing.T) {
+	captor := processtest.NewEventsCaptor(process.DiedEventType)
+	captor.Capture()
+	p, err := process.NewBuilder().
+		CmdName("test").
+		CmdLine("echo ready; sleep 10").
+		SubscribeDefault("events-captor", captor).
+		Start()
+	if err != nil {
+		captor.Stop()
+		t.Fatal(err)
+	}
+
+	waitEventsCaptured(captor, 2)
+	if err := process.Signal(p.Pid, syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	if ok := <-captor.Wait(time.Second * 2); !ok {
+		process.Kill(p.Pid)
+		t.Fatal("Process doesn't finish its execution in 2 seconds after SIGTERM")
+	}
+
+	events := captor.Events()
+	diedEvent := events[len(events)-1].(*process.DiedEvent)
+	if diedEvent.Signal != "TERM" {
+		t.Fatalf("Expected died event signal to be 'TERM', but it is '%s'", diedEvent.Signal)
+	}
+}
+
+func TestKillGracefullyKillsProcessIgnoringTerm(t *testing.T) {
+	captor := processtest.NewEventsCaptor(process.DiedEventType)
+	captor.Capture()
+	p, err := process.NewBuilder().
+		CmdName("test").
+		CmdLine("trap '' TERM; echo ready; while true; do sleep 0.1; done").
+		SubscribeDefault("events-captor", captor).
+		Start()
+	if err != nil {
+		captor.Stop()
+		t.Fatal(err)
+	}
+
+	// wait for the trap to be installed
+	waitEventsCaptured(captor, 2)
+	if err := process.KillGracefully(p.Pid, 200*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if ok := <-captor.Wait(time.Second * 2); !ok {
+		process.Kill(p.Pid)
+		t.Fatal("Process is not killed after grace period")
+	}
+
+	events := captor.Events()
+	diedEvent := events[len(events)-1].(*process.DiedEvent)
+	if diedEvent.Signal != "KILL" {
+		t.Fatalf("Expected died event signal to be 'KILL', but it is '%s'", diedEvent.Signal)
+	}
+	if diedEvent.Reason != process.KilledReason {
+		t.Fatalf("Expected died event reason to be '%s', but it is '%s'", process.KilledReason, diedEvent.Reason)
+	}
+}
+
+func TestProcessIsTerminatedWhenTimeoutElapses(t *testing.T) {
+	captor := processtest.NewEventsCaptor(process.DiedEventType)
+	captor.Capture()
+	p, err := process.NewBuilder().
+		CmdName("test").
+		CmdLine("sleep 10").
+		CmdTimeout(1).
+		SubscribeDefault("events-captor", captor).
+		Start()
+	if err != nil {
+		captor.Stop()
+		t.Fatal(err)
+	}
+
+	if ok := <-captor.Wait(time.Second * 3); !ok {
+		process.Kill(p.Pid)
+		t.Fatal("Process is not terminated after its timeout elapsed")
+	}
+
+	events := captor.Events()
+	diedEvent := events[len(events)-1].(*process.DiedEvent)
+	if diedEvent.Reason != process.TimeoutReason {
+		t.Fatalf("Expected died event reason to be '%s', but it is '%s'", process.TimeoutReason, diedEvent.Reason)
+	}
+}
+
+func TestProcessIsRestartedOnFailure(t *testing.T) {
+	captor := processtest.NewEventsCaptor(process.DiedEventType)
+	captor.Capture()
+	command := process.Command{
+		Name:           "test",
+		CommandLine:    "echo run; exit 1",
+		RestartPolicy:  process.RestartOnFailure,
+		MaxRetries:     2,
+		RestartBackoff: 10,
+	}
+	p, err := process.NewBuilder().Cmd(command).SubscribeDefault("events-captor", captor).Start()
+	if err != nil {
+		captor.Stop()
+		t.Fatal(err)
+	}
+	if ok := <-captor.Wait(time.Second * 3); !ok {
+		process.Kill(p.Pid)
+		t.Fatal("Process doesn't finish its execution in 3 seconds")
+	}
+
+	checkEventsOrder(t, captor.Events(),
+		process.StartedEventType,
+		process.StdoutEventType,
+		process.RestartingEventType,
+		process.StartedEventType,
+		process.StdoutEventType,
+		process.RestartingEventType,
+		process.StartedEventType,
+		process.StdoutEventType,
+		process.DiedEventType)
+	result, err := process.Get(p.Pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RestartCount != 2 || result.ExitCode != 1 {
+		t.Fatalf("Expected process to be restarted 2 times and exit with 1, but got %v", result)
+	}
+}
+
+func TestReadinessIsCheckedOnEachRestart(t *testing.T) {
+	captor := processtest.NewEventsCaptor(process.DiedEventType)
+	captor.Capture()
+	command := process.Command{
+		Name:           "test",
+		CommandLine:    "echo started; sleep 0.1; exit 1",
+		RestartPolicy:  process.RestartOnFailure,
+		MaxRetries:     1,
+		RestartBackoff: 10,
+		Readiness:      &process.ReadinessProbe{Pattern: "started"},
+	}
+	p, err := process.NewBuilder().Cmd(command).Subscribe("events-captor", process.StatusBit, captor).Start()
+	if err != nil {
+		captor.Stop()
+		t.Fatal(err)
+	}
+	if ok := <-captor.Wait(time.Second * 3); !ok {
+		process.Kill(p.Pid)
+		t.Fatal("Process doesn't finish its execution in 3 seconds")
+	}
+
+	checkEventsOrder(t, captor.Events(),
+		process.StartedEventType,
+		process.ReadyEventType,
+		process.RestartingEventType,
+		process.StartedEventType,
+		process.ReadyEventType,
+		process.DiedEventType)
+}
+
+func TestProcessIsNotStartedIfReadinessProbeIsInvalid(t *testing.T) {
+	marker := tmpFile()
+	command := process.Command{
+		Name:        "test",
+		CommandLine: "touch " + marker,
+		Readiness:   &process.ReadinessProbe{Port: -1},
+	}
+	if _, err := process.NewBuilder().Cmd(command).Start(); err == nil {
+		t.Fatal("Expected process with invalid readiness probe not to start")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		os.Remove(marker)
+		t.Fatal("Expected native process not to be started")
+	}
+}
+
+func TestReadinessPatternIsRejectedUnlessOutputIsSplitByLines(t *testing.T) {
+	probe := &process.ReadinessProbe{Pattern: "ready"}
+	for _, mode := range []string{process.ChunksOutputMode, process.RawOutputMode} {
+		if err := process.CheckReadinessOutput(probe, &process.OutputOptions{Mode: mode}); err == nil {
+			t.Fatalf("Expected readiness pattern to be rejected in '%s' output mode", mode)
+		}
+		command := process.Command{
+			Name:        "test",
+			CommandLine: "echo ready",
+			Readiness:   probe,
+			Output:      &process.OutputOptions{Mode: mode},
+		}
+		if _, err := process.NewBuilder().Cmd(command).Start(); err == nil {
+			t.Fatalf("Expected process with readiness pattern not to start in '%s' output mode", mode)
+		}
+	}
+	if err := process.CheckReadinessOutput(probe, nil); err != nil {
+		t.Fatalf("Expected readiness pattern to be allowed in lines output mode, but got %s", err)
+	}
+	if err := process.CheckReadinessOutput(&process.ReadinessProbe{Port: 8080}, &process.OutputOptions{Mode: process.RawOutputMode}); err != nil {
+		t.Fatalf("Expected readiness port to be allowed in raw output mode, but got %s", err)
+	}
+}
+
+func TestKillCancelsProcessRestart(t *testing.T) {
+	captor := processtest.NewEventsCaptor(process.DiedEventType)
+	captor.Capture()
+	command := process.Command{
+		Name:           "test",
+		CommandLine:    "exit 0",
+		RestartPolicy:  process.RestartAlways,
+		RestartBackoff: 10000,
+	}
+	p, err := process.NewBuilder().Cmd(command).SubscribeDefault("events-captor", captor).Start()
+	if err != nil {
+		captor.Stop()
+		t.Fatal(err)
+	}
+
+	waitEventsCaptured(captor, 2)
+	if err := process.Kill(p.Pid); err != nil {
+		t.Fatal(err)
+	}
+	if ok := <-captor.Wait(time.Second * 2); !ok {
+		t.Fatal("Process waiting for restart is not dead after kill")
+	}
+	checkEventsOrder(t, captor.Events(),
+		process.StartedEventType,
+		process.RestartingEventType,
+		process.DiedEventType)
+}
+
+func TestSignalFailsWhileProcessWaitsForRestart(t *testing.T) {
+	captor := processtest.NewEventsCaptor(process.DiedEventType)
+	captor.Capture()
+	command := process.Command{
+		Name:           "test",
+		CommandLine:    "exit 0",
+		RestartPolicy:  process.RestartAlways,
+		RestartBackoff: 10000,
+	}
+	p, err := process.NewBuilder().Cmd(command).SubscribeDefault("events-captor", captor).Start()
+	if err != nil {
+		captor.Stop()
+		t.Fatal(err)
+	}
+	defer process.Kill(p.Pid)
+
+	waitEventsCaptured(captor, 2)
+	if err := process.Signal(p.Pid, syscall.SIGTERM); err == nil {
+		t.Fatal("Expected signal to fail as the native process of the process waiting for restart has exited")
+	}
+}
+
+func TestProcessIsReadyWhenOutputMatchesPattern(t *testing.T) {
+	captor := processtest.NewEventsCaptor(process.DiedEventType)
+	captor.Capture()
+	command := process.Command{
+		Name:        "test",
+		CommandLine: "echo starting; echo 'server started on 8080'; echo done",
+		Readiness:   &process.ReadinessProbe{Pattern: "started on \\d+"},
+	}
+	p, err := process.NewBuilder().Cmd(command).SubscribeDefault("events-captor", captor).Start()
+	if err != nil {
+		captor.Stop()
+		t.Fatal(err)
+	}
+	if p.Ready {
+		t.Fatal("Process with readiness probe must not be ready right after start")
+	}
+	if ok := <-captor.Wait(time.Second * 2); !ok {
+		t.Fatal("Process doesn't finish its execution in 2 seconds")
+	}
+
+	checkEventsOrder(t, captor.Events(),
+		process.StartedEventType,
+		process.StdoutEventType,
+		process.StdoutEventType,
+		process.ReadyEventType,
+		process.StdoutEventType,
+		process.DiedEventType)
+	result, _ := process.Get(p.Pid)
+	if !result.Ready {
+		t.Fatal("Expected process to be ready")
+	}
+}
+
+func TestProcessIsNotReadyIfDiedBeforeBecameReady(t *testing.T) {
+	captor := processtest.NewEventsCaptor(process.DiedEventType)
+	captor.Capture()
+	command := process.Command{
+		Name:        "test",
+		CommandLine: "exit 1",
+		Readiness:   &process.ReadinessProbe{Port: 1},
+	}
+	if _, err := process.NewBuilder().Cmd(command).SubscribeDefault("events-captor", captor).Start(); err != nil {
+		captor.Stop()
+		t.Fatal(err)
+	}
+	if ok := <-captor.Wait(time.Second * 2); !ok {
+		t.Fatal("Process doesn't finish its execution in 2 seconds")
+	}
+
+	checkEventsOrder(t, captor.Events(),
+		process.StartedEventType,
+		process.NotReadyEventType,
+		process.DiedEventType)
+}
+
+func TestGetStatsOfProcessSession(t *testing.T) {
+	captor := processtest.NewEventsCaptor(process.DiedEventType)
+	captor.Capture()
+	p, err := process.NewBuilder().
+		CmdName("test").
+		CmdLine("sleep 10 & wait").
+		SubscribeDefault("events-captor", captor).
+		Start()
+	if err != nil {
+		captor.Stop()
+		t.Fatal(err)
+	}
+	defer process.Kill(p.Pid)
+
+	// wait for the shell to start sleep
+	var stats *process.Stats
+	for i := 0; i < 20 && (stats == nil || len(stats.Children) == 0); i++ {
+		time.Sleep(50 * time.Millisecond)
+		if stats, err = process.GetStats(p.Pid); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats.NativePid != p.NativePid || stats.RSS <= 0 || stats.Threads < 2 || stats.OpenFiles <= 0 {
+		t.Fatalf("Expected stats of both shell and sleep processes, but got %v", stats)
+	}
+	if len(stats.Children) != 1 {
+		t.Fatalf("Expected sleep to be the only child in process session, but got %v", stats.Children)
+	}
+}
+
+func TestGetTreeAndSignalDescendant(t *testing.T) {
+	p, err := process.NewBuilder().
+		CmdName("test").
+		CmdLine("sleep 10 & wait").
+		Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer process.Kill(p.Pid)
+
+	// wait for the shell to start sleep
+	var tree *process.TreeNode
+	for i := 0; i < 20 && (tree == nil || len(tree.Children) == 0); i++ {
+		time.Sleep(50 * time.Millisecond)
+		if tree, err = process.GetTree(p.Pid); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if tree.NativePid != p.NativePid || len(tree.Argv) == 0 || tree.StartTime.IsZero() {
+		t.Fatalf("Expected tree root to be the process itself, but got %v", tree)
+	}
+	if len(tree.Children) != 1 || tree.Children[0].Argv[0] != "sleep" || tree.Children[0].Ppid != p.NativePid {
+		t.Fatalf("Expected sleep to be the only child of the process, but got %v", tree.Children)
+	}
+
+	if err := process.SignalNative(p.Pid, os.Getpid(), syscall.SIGTERM); err == nil {
+		t.Fatal("Expected error when signaling native process out of the process session")
+	} else if _, ok := err.(*process.NotInSessionError); !ok {
+		t.Fatalf("Expected NotInSessionError but got %v", err)
+	}
+
+	// killing sleep lets the shell exit
+	if err := process.SignalNative(p.Pid, tree.Children[0].NativePid, syscall.SIGKILL); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20 && p.Alive; i++ {
+		time.Sleep(50 * time.Millisecond)
+		p, _ = process.Get(p.Pid)
+	}
+	if p.Alive {
+		t.Fatal("Expected process to exit after its child is killed")
+	}
+}
+
+func TestGetTreeIncludesReparentedSessionMembers(t *testing.T) {
+	p, err := process.NewBuilder().
+		CmdName("test").
+		CmdLine("(sleep 10 &); sleep 10 & wait").
+		Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer process.Kill(p.Pid)
+
+	// wait for the shell to start both sleeps, the first one is reparented as its parent exits
+	var tree *process.TreeNode
+	for i := 0; i < 20 && (tree == nil || len(tree.Children) < 2); i++ {
+		time.Sleep(50 * time.Millisecond)
+		if tree, err = process.GetTree(p.Pid); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(tree.Children) != 2 {
+		t.Fatalf("Expected both sleeps to be the children of the process, but got %v", tree.Children)
+	}
+	reparented := 0
+	for _, child := range tree.Children {
+		if child.Argv[0] != "sleep" {
+			t.Fatalf("Expected sleep to be the child of the process, but got %v", child)
+		}
+		if child.Ppid != p.NativePid {
+			reparented++
+		}
+	}
+	if reparented != 1 {
+		t.Fatalf("Expected one of sleeps to be reparented, but got %d", reparented)
+	}
+}
+
+func TestProcessOpenFilesLimit(t *testing.T) {
+	captor := processtest.NewEventsCaptor(process.DiedEventType)
+	captor.Capture()
+	p, err := process.NewBuilder().
+		CmdName("test").
+		CmdLine("ulimit -n").
+		CmdLimits(&process.Limits{MaxOpenFiles: 64}).
+		SubscribeDefault("events-captor", captor).
+		Start()
+	if err != nil {
+		captor.Stop()
+		t.Fatal(err)
+	}
+
+	if ok := <-captor.Wait(time.Second * 2); !ok {
+		t.Fatalf("Process %d doesn't finish its execution in 2 seconds", p.Pid)
+	}
+	events := captor.Events()
+	if text := events[1].(*process.OutputEvent).Text; text != "64" {
+		t.Fatalf("Expected open files limit to be 64, but it is '%s'", text)
+	}
+	died, _ := process.Get(p.Pid)
+	if died.Limits == nil || died.Limits.MaxOpenFiles != 64 {
+		t.Fatalf("Expected process to keep its limits, but got %v", died.Limits)
+	}
+}
+
+func TestProcessMemoryIsLimitedByRlimitIfCgroupsAreNotDelegated(t *testing.T) {
+	process.SetLimitsCgroup("/" + strconv.FormatInt(time.Now().UnixNano(), 36))
+	defer process.SetLimitsCgroup("")
+	captor := processtest.NewEventsCaptor(process.DiedEventType)
+	captor.Capture()
+	p, err := process.NewBuilder().
+		CmdName("test").
+		CmdLine("ulimit -v").
+		CmdLimits(&process.Limits{Memory: 512 * 1024 * 1024}).
+		SubscribeDefault("events-captor", captor).
+		Start()
+	if err != nil {
+		captor.Stop()
+		t.Fatal(err)
+	}
+
+	if ok := <-captor.Wait(time.Second * 2); !ok {
+		t.Fatalf("Process %d doesn't finish its execution in 2 seconds", p.Pid)
+	}
+	events := captor.Events()
+	if text := events[1].(*process.OutputEvent).Text; text != "524288" {
+		t.Fatalf("Expected virtual memory limit to be 524288 kbytes, but it is '%s'", text)
+	}
+}
+
+func TestProcessWithCpuLimitsDoesNotStartIfCgroupsAreNotDelegated(t *testing.T) {
+	process.SetLimitsCgroup("/" + strconv.FormatInt(time.Now().UnixNano(), 36))
+	defer process.SetLimitsCgroup("")
+
+	_, err := process.NewBuilder().
+		CmdName("test").
+		CmdLine("echo test").
+		CmdLimits(&process.Limits{CPUShares: 200}).
+		Start()
+	if err == nil {
+		t.Fatal("Expected process with cpu limits not to start without delegated cgroup")
+	}
+}
+
+func TestChunksOutputModeIsStreamedAndPersisted(t *testing.T) {
+	process.SetLogsDir(tmpFile())
+	defer wipeLogs()
+	captor := processtest.NewEventsCaptor(process.DiedEventType)
+	captor.Capture()
+	p, err := process.NewBuilder().
+		CmdName("test").
+		CmdLine("printf '10%%\\r'; sleep 0.3; printf '20%%\\r'").
+		CmdOutput(&process.OutputOptions{Mode: process.ChunksOutputMode, ChunkInterval: 50}).
+		SubscribeDefault("events-captor", captor).
+		Start()
+	if err != nil {
+		captor.Stop()
+		t.Fatal(err)
+	}
+	if ok := <-captor.Wait(time.Second * 2); !ok {
+		t.Fatalf("Process %d doesn't finish its execution in 2 seconds", p.Pid)
+	}
+
+	var texts []string
+	for _, event := range captor.Events() {
+		if output, ok := event.(*process.OutputEvent); ok {
+			if output.Mode != process.ChunksOutputMode {
+				t.Fatalf("Expected output event mode to be '%s' but got '%s'", process.ChunksOutputMode, output.Mode)
+			}
+			texts = append(texts, output.Text)
+		}
+	}
+	if len(texts) != 2 || texts[0] != "10%\r" || texts[1] != "20%\r" {
+		t.Fatalf("Expected partial lines to be streamed as separate chunks but got %q", texts)
+	}
+
+	logs, err := process.ReadAllLogs(p.Pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 2 || logs[0].Mode != process.ChunksOutputMode || logs[1].Text != "20%\r" {
+		t.Fatalf("Expected chunks to be persisted along with the mode but got %v", logs)
+	}
+}
+
+func TestStripAnsiStripsPersistedLogsOnly(t *testing.T) {
+	process.SetLogsDir(tmpFile())
+	defer wipeLogs()
+	captor := processtest.NewEventsCaptor(process.DiedEventType)
+	captor.Capture()
+	p, err := process.NewBuilder().
+		CmdName("test").
+		CmdLine("printf '\\033[31mred\\033[0m\\n'").
+		CmdStripAnsi().
+		SubscribeDefault("events-captor", captor).
+		Start()
+	if err != nil {
+		captor.Stop()
+		t.Fatal(err)
+	}
+	if ok := <-captor.Wait(time.Second * 2); !ok {
+		t.Fatalf("Process %d doesn't finish its execution in 2 seconds", p.Pid)
+	}
+
+	for _, event := range captor.Events() {
+		if output, ok := event.(*process.OutputEvent); ok && output.Text != "\x1b[31mred\x1b[0m" {
+			t.Fatalf("Expected output event to be raw but got %q", output.Text)
+		}
+	}
+	logs, err := process.ReadAllLogs(p.Pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1 || logs[0].Text != "red" {
+		t.Fatalf("Expected persisted logs to be stripped but got %v", logs)
+	}
+}
+
+func TestProcessRunsUnderTty(t *testing.T) {
+	captor := processtest.NewEventsCaptor(process.DiedEventType)
+	captor.Capture()
+	p, err := process.NewBuilder().
+		CmdName("test").
+		CmdLine("test -t 1 && echo tty; echo $TERM; stty size; echo err >&2").
+		CmdTty(100, 30).
+		SubscribeDefault("events-captor", captor).
+		Start()
+	if err != nil {
+		captor.Stop()
+		t.Fatal(err)
+	}
+	if ok := <-captor.Wait(time.Second * 2); !ok {
+		t.Fatalf("Process %d doesn't finish its execution in 2 seconds", p.Pid)
+	}
+
+	var lines []string
+	for _, event := range captor.Events() {
+		if event.Type() == process.StdoutEventType {
+			lines = append(lines, event.(*process.OutputEvent).Text)
+		}
+	}
+	expected := []string{"tty", "xterm-256color", "30 100", "err"}
+	if strings.Join(lines, "|") != strings.Join(expected, "|") {
+		t.Fatalf("Expected merged terminal output %q but got %q", expected, lines)
+	}
+}
+
+func TestResizesProcessTty(t *testing.T) {
+	captor := processtest.NewEventsCaptor(process.DiedEventType)
+	captor.Capture()
+	p, err := process.NewBuilder().
+		CmdName("test").
+		CmdLine("sleep 0.3; stty size").
+		CmdTty(0, 0).
+		SubscribeDefault("events-captor", captor).
+		Start()
+	if err != nil {
+		captor.Stop()
+		t.Fatal(err)
+	}
+	if err := process.Resize(p.Pid, 120, 40); err != nil {
+		t.Fatal(err)
+	}
+	if ok := <-captor.Wait(time.Second * 2); !ok {
+		t.Fatalf("Process %d doesn't finish its execution in 2 seconds", p.Pid)
+	}
+
+	events := captor.Events()
+	if text := events[1].(*process.OutputEvent).Text; text != "40 120" {
+		t.Fatalf("Expected terminal size to be '40 120' but got '%s'", text)
+	}
+	resized, _ := process.Get(p.Pid)
+	if resized.Cols != 120 || resized.Rows != 40 {
+		t.Fatalf("Expected process to keep its terminal size, but got %dx%d", resized.Cols, resized.Rows)
+	}
+	if err := process.Resize(p.Pid, 80, 24); err == nil {
+		t.Fatal("Expected an error when resizing the terminal of dead process")
+	}
+}
+
+func TestProcessExecutesArgvWithoutShell(t *testing.T) {
+	captor := processtest.NewEventsCaptor(process.DiedEventType)
+	captor.Capture()
+	p, err := process.NewBuilder().
+		CmdName("test").
+		CmdArgv("printf", "%s|%s", "it's $HOME", "\"a b\"").
+		SubscribeDefault("events-captor", captor).
+		Start()
+	if err != nil {
+		captor.Stop()
+		t.Fatal(err)
+	}
+	if ok := <-captor.Wait(time.Second * 2); !ok {
+		t.Fatalf("Process %d doesn't finish its execution in 2 seconds", p.Pid)
+	}
+
+	events := captor.Events()
+	if text := events[1].(*process.OutputEvent).Text; text != "it's $HOME|\"a b\"" {
+		t.Fatalf("Expected arguments to be passed as is, but the output is '%s'", text)
+	}
+}
+
+func TestProcessRunsByAllowedInterpreter(t *testing.T) {
+	if err := process.SetAllowedInterpreters([]string{"sh", "echo=echo interpreted"}); err != nil {
+		t.Fatal(err)
+	}
+	defer process.SetAllowedInterpreters(nil)
+
+	captor := processtest.NewEventsCaptor(process.DiedEventType)
+	captor.Capture()
+	p, err := process.NewBuilder().
+		CmdName("test").
+		CmdLine("command line").
+		CmdInterpreter("echo").
+		SubscribeDefault("events-captor", captor).
+		Start()
+	if err != nil {
+		captor.Stop()
+		t.Fatal(err)
+	}
+	if ok := <-captor.Wait(time.Second * 2); !ok {
+		t.Fatalf("Process %d doesn't finish its execution in 2 seconds", p.Pid)
+	}
+	if text := captor.Events()[1].(*process.OutputEvent).Text; text != "interpreted command line" {
+		t.Fatalf("Expected command line to be passed to the interpreter, but the output is '%s'", text)
+	}
+
+	if _, err := process.NewBuilder().CmdName("test").CmdLine("1").CmdInterpreter("python").Start(); err == nil {
+		t.Fatal("Expected the process not to start by not allowed interpreter")
+	}
+	if err := process.SetAllowedInterpreters([]string{"unknown"}); err == nil {
+		t.Fatal("Expected unknown interpreter without program to be rejected")
+	}
+}
+
+func TestProcessRunsAsAllowedUser(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("Switching user requires root")
+	}
+	process.SetAllowedUsers([]string{"nobody"})
+	defer process.SetAllowedUsers(nil)
+
+	captor := processtest.NewEventsCaptor(process.DiedEventType)
+	captor.Capture()
+	p, err := process.NewBuilder().
+		CmdName("test").
+		CmdLine("id -u && echo $USER").
+		CmdUser("nobody").
+		SubscribeDefault("events-captor", captor).
+		Start()
+	if err != nil {
+		captor.Stop()
+		t.Fatal(err)
+	}
+	if ok := <-captor.Wait(time.Second * 2); !ok {
+		t.Fatalf("Process %d doesn't finish its execution in 2 seconds", p.Pid)
+	}
+
+	events := captor.Events()
+	uid := events[1].(*process.OutputEvent).Text
+	if uid == "0" || uid != strconv.Itoa(p.UID) {
+		t.Fatalf("Expected process to run as nobody with uid %d, but it runs as '%s'", p.UID, uid)
+	}
+	if user := events[2].(*process.OutputEvent).Text; user != "nobody" {
+		t.Fatalf("Expected USER to be nobody, but it is '%s'", user)
+	}
+}
+
+func TestProcessDoesNotStartAsNotAllowedUser(t *testing.T) {
+	process.SetAllowedUsers([]string{"nobody"})
+	defer process.SetAllowedUsers(nil)
+
+	if err := process.CheckUser("root"); err == nil {
+		t.Fatal("Expected error when checking not allowed user")
+	}
+	_, err := process.NewBuilder().
+		CmdName("test").
+		CmdLine("id -u").
+		CmdUser("root").
+		Start()
+	if err == nil {
+		t.Fatal("Expected process not to start as not allowed user")
+	}
+}
+
+func TestUserGroupMustBeUserGroupOrAllowed(t *testing.T) {
+	nobody, err := user.Lookup("nobody")
+	if err != nil {
+		t.Skip("User nobody doesn't exist")
+	}
+	process.SetAllowedUsers([]string{"nobody"})
+	defer process.SetAllowedUsers(nil)
+
+	if err := process.CheckUser("nobody:0"); err == nil {
+		t.Fatal("Expected error when checking not allowed group of allowed user")
+	}
+	if err := process.CheckUser("nobody:" + nobody.Gid); err != nil {
+		t.Fatalf("Expected the group of the user to be allowed, but got %s", err)
+	}
+
+	process.SetAllowedGroups([]string{"0"})
+	defer process.SetAllowedGroups(nil)
+	if err := process.CheckUser("nobody:0"); err != nil {
+		t.Fatalf("Expected allowed group to be allowed, but got %s", err)
+	}
+}
+
+func TestUserAndGroupAreResolvedByNames(t *testing.T) {
+	root, err := user.LookupId("0")
+	if err != nil {
+		t.Skip("User with uid 0 doesn't exist")
+	}
+	group, err := user.LookupGroupId(root.Gid)
+	if err != nil {
+		t.Skip("The group of user with uid 0 doesn't exist")
+	}
+	process.SetAllowedUsers([]string{"0"})
+	defer process.SetAllowedUsers(nil)
+
+	if err := process.CheckUser(root.Username + ":" + group.Name); err != nil {
+		t.Fatalf("Expected user and group names to be resolved, but got %s", err)
+	}
+	if err := process.CheckUser(root.Username + ":not-existing-group"); err == nil {
+		t.Fatal("Expected error when checking not existing group")
+	}
+}
+
+func TestGetProcessesFilteredByLabelsSortedAndPaged(t *testing.T) {
+	panel := strconv.FormatInt(time.Now().UnixNano(), 36)
+	for _, name := range []string{"b", "a", "c"} {
+		_, err := process.NewBuilder().
+			CmdName(name).
+			CmdLine("sleep 10").
+			CmdLabels(map[string]string{"panel": panel, "name": name}).
+			Start()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	alive := true
+	all := process.GetProcesses(process.Filter{Alive: &alive})
+	defer func() {
+		for _, p := range process.GetProcesses(process.Filter{Selector: process.Selector{{Key: "panel", Value: panel}}}) {
+			process.Kill(p.Pid)
+		}
+	}()
+	if len(all) < 3 {
+		t.Fatalf("Expected at least 3 alive processes, but got %d", len(all))
+	}
+
+	selector, err := process.ParseSelector("panel=" + panel + ",name!=b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filtered := process.GetProcesses(process.Filter{
+		Selector: selector,
+		SortBy:   process.SortByName,
+		Desc:     true,
+	})
+	if len(filtered) != 2 || filtered[0].Name != "c" || filtered[1].Name != "a" {
+		t.Fatalf("Expected processes 'c' and 'a' but got %v", filtered)
+	}
+
+	paged := process.GetProcesses(process.Filter{
+		Selector: process.Selector{{Key: "panel", Value: panel}},
+		Skip:     1,
+		Limit:    1,
+	})
+	if len(paged) != 1 || paged[0].Name != "a" {
+		t.Fatalf("Expected the second started process 'a' but got %v", paged)
+	}
+}
+
+func TestParseSelector(t *testing.T) {
+	selector, err := process.ParseSelector("panel=debug, !temporary,owner!=ide,keep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	matching := map[string]string{"panel": "debug", "owner": "user", "keep": ""}
+	if !selector.Matches(matching) {
+		t.Fatalf("Expected selector %v to match labels %v", selector, matching)
+	}
+	for _, labels := range []map[string]string{
+		{"panel": "run", "keep": ""},
+		{"panel": "debug", "keep": "", "temporary": "true"},
+		{"panel": "debug", "keep": "", "owner": "ide"},
+		{"panel": "debug"},
+	} {
+		if selector.Matches(labels) {
+			t.Fatalf("Expected selector %v not to match labels %v", selector, labels)
+		}
+	}
+	if _, err := process.ParseSelector("=value"); err == nil {
+		t.Fatal("Expected error when parsing selector without label key")
+	}
+}
+
+func TestWaitReturnsDeadProcessToAllWaiters(t *testing.T) {
+	p, err := process.NewBuilder().
+		CmdName("test").
+		CmdLine("sleep 0.3 && exit 3").
+		Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := make(chan process.MachineProcess, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			waited, err := process.Wait(p.Pid, 5*time.Second)
+			if err != nil {
+				t.Error(err)
+			}
+			results <- waited
+		}()
+	}
+	for i := 0; i < 3; i++ {
+		waited := <-results
+		if waited.Alive || waited.ExitCode != 3 {
+			t.Fatalf("Expected waiter to receive dead process with exit code 3, but got %v", waited)
+		}
+	}
+
+	// waiting for dead process returns immediately
+	if waited, err := process.Wait(p.Pid, time.Minute); err != nil || waited.Alive {
+		t.Fatalf("Expected dead process to be returned, but got %v, %v", waited, err)
+	}
+}
+
+func TestWaitReturnsAliveProcessWhenTimeoutElapses(t *testing.T) {
+	p, err := process.NewBuilder().
+		CmdName("test").
+		CmdLine("sleep 10").
+		Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer process.Kill(p.Pid)
+
+	waited, err := process.Wait(p.Pid, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !waited.Alive {
+		t.Fatal("Expected process to be alive after wait timeout")
+	}
+}
+
+func TestWaitOrCancelStopsWaitingWhenCanceled(t *testing.T) {
+	p, err := process.NewBuilder().
+		CmdName("test").
+		CmdLine("sleep 10").
+		Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer process.Kill(p.Pid)
+
+	cancel := make(chan struct{})
+	time.AfterFunc(100*time.Millisecond, func() { close(cancel) })
+	waited, err := process.WaitOrCancel(p.Pid, 0, cancel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !waited.Alive {
+		t.Fatal("Expected process to be alive after wait is canceled")
+	}
+}
+
+func TestSearchLogs(t *testing.T) {
+	logsDir := tmpFile()
+	defer wipeLogs()
+	p1 := doStartAndWaitTestProcess("printf \"a\nBUILD FAILURE\nb\nc\n\"", logsDir, processtest.NewEventsCaptor(process.DiedEventType), t)
+	p2 := doStartAndWaitTestProcess("echo BUILD FAILURE >&2", logsDir, processtest.NewEventsCaptor(process.DiedEventType), t)
+	pattern := regexp.MustCompile("BUILD FAIL")
+
+	result, err := process.SearchLogs(process.SearchQuery{Pattern: pattern, Pids: []uint64{p2.Pid, p1.Pid}, Context: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Matches) != 2 || result.HasMore {
+		t.Fatalf("Expected 2 matches, but found %v", result)
+	}
+	first := result.Matches[0]
+	if first.Pid != p1.Pid || first.Line != 2 || first.Text != "BUILD FAILURE" {
+		t.Fatalf("Unexpected first match %v", first)
+	}
+	if len(first.Before) != 1 || first.Before[0].Text != "a" || len(first.After) != 1 || first.After[0].Text != "b" {
+		t.Fatalf("Unexpected context of the first match, before %v, after %v", first.Before, first.After)
+	}
+	if second := result.Matches[1]; second.Pid != p2.Pid || second.Line != 1 || second.Kind != process.StderrKind {
+		t.Fatalf("Unexpected second match %v", second)
+	}
+
+	stderr := process.StderrKind
+	result, err = process.SearchLogs(process.SearchQuery{Pattern: pattern, Pids: []uint64{p1.Pid, p2.Pid}, Kind: &stderr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Matches) != 1 || result.Matches[0].Pid != p2.Pid {
+		t.Fatalf("Expected only stderr match, but found %v", result.Matches)
+	}
+
+	result, err = process.SearchLogs(process.SearchQuery{Pattern: pattern, Pids: []uint64{p1.Pid, p2.Pid}, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Matches) != 1 || result.Matches[0].Pid != p1.Pid || !result.HasMore {
+		t.Fatalf("Expected the first page to contain the match of the first process, but found %v", result)
+	}
+
+	result, err = process.SearchLogs(process.SearchQuery{Pattern: pattern, Pids: []uint64{p1.Pid, p2.Pid}, Skip: 1, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Matches) != 1 || result.Matches[0].Pid != p2.Pid || result.HasMore {
+		t.Fatalf("Expected the second page to contain the match of the second process, but found %v", result)
+	}
+}
+
+func TestParseSignal(t *testing.T) {
+	for _, name := range []string{"TERM", "term", "SIGTERM", " sigterm "} {
+		if sig, err := process.ParseSignal(name); err != nil || sig != syscall.SIGTERM {
+			t.Fatalf("Expected '%s' to be parsed as SIGTERM", name)
+		}
+	}
+	if _, err := process.ParseSignal("SEGV"); err == nil {
+		t.Fatal("Expected error when parsing not supported signal")
+	}
+}
+
+// Waits at most a second until captor captures given number of events.
+func waitEventsCaptured(captor *processtest.EventsCaptor, count int) {
+	for i := 0; i < 20 && len(captor.Events()) < count; i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
 func checkEventsOrder(t *testing.T, events []process.Event, types ...string) {
 	if len(types) != len(events) {
 		t.Fatalf("Expected receive %d events while received %d", len(types), len(events))
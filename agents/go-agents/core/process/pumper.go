@@ -12,9 +12,9 @@
 package process
 
 import (
-	"bufio"
 	"io"
-	"log"
+	"os"
+	"strings"
 	"sync"
 	"time"
 )
@@ -40,10 +40,17 @@ type LogsPumper struct {
 	stderr    io.Reader
 	clients   []LogsConsumer
 	waitGroup sync.WaitGroup
+
+	// defines how the output is split, nil means the default lines mode
+	output *OutputOptions
+
+	// the master side of the pseudo-terminal the output is read from,
+	// nil if the process doesn't run under a terminal
+	tty *os.File
 }
 
 // NewPumper creates new instance of LogsPumper from provided
-// stdout and stderr readers
+// stdout and stderr readers, stderr may be nil if it's merged into stdout
 func NewPumper(stdout io.Reader, stderr io.Reader) *LogsPumper {
 	return &LogsPumper{
 		stdout: stdout,
@@ -51,6 +58,11 @@ func NewPumper(stdout io.Reader, stderr io.Reader) *LogsPumper {
 	}
 }
 
+// SetOutput sets the options of the output capturing, must be called before pumping is started.
+func (pumper *LogsPumper) SetOutput(options *OutputOptions) {
+	pumper.output = options
+}
+
 // AddConsumer adds provided LogsConsumer into logs consumers list of the pumper
 func (pumper *LogsPumper) AddConsumer(consumer LogsConsumer) {
 	pumper.clients = append(pumper.clients, consumer)
@@ -60,33 +72,36 @@ func (pumper *LogsPumper) AddConsumer(consumer LogsConsumer) {
 // The method execution is synchronous and waits for
 // both stderr and stdout to complete closing all the clients after
 func (pumper *LogsPumper) Pump() {
-	pumper.waitGroup.Add(2)
-
 	// reading from stdout & stderr
-	go pump(pumper.stdout, pumper.notifyStdout, &pumper.waitGroup)
-	go pump(pumper.stderr, pumper.notifyStderr, &pumper.waitGroup)
+	pumper.waitGroup.Add(1)
+	go pumper.pump(pumper.stdout, pumper.notifyStdout)
+	if pumper.stderr != nil {
+		pumper.waitGroup.Add(1)
+		go pumper.pump(pumper.stderr, pumper.notifyStderr)
+	}
 
 	// cleanup after pumping is complete
 	pumper.waitGroup.Wait()
+	if pumper.tty != nil {
+		closeFile(pumper.tty)
+	}
 	pumper.notifyClose()
 }
 
-func pump(r io.Reader, lineConsumer acceptLine, wg *sync.WaitGroup) {
-	defer wg.Done()
-	br := bufio.NewReader(r)
-	for {
-		line, err := br.ReadBytes('\n')
-
-		if err != nil {
-			if err != io.EOF {
-				log.Println("Error pumping: " + err.Error())
-			} else if len(line) != 0 {
-				lineConsumer(string(line))
-			}
-			return
-		}
-
-		lineConsumer(string(line[:len(line)-1]))
+func (pumper *LogsPumper) pump(r io.Reader, lineConsumer acceptLine) {
+	defer pumper.waitGroup.Done()
+	options := pumper.output.withDefaults()
+	if pumper.tty != nil && options.Mode == LinesOutputMode {
+		// the terminal translates new lines into '\r\n'
+		consumer := lineConsumer
+		lineConsumer = func(line string) { consumer(strings.TrimSuffix(line, "\r")) }
+	}
+	switch options.Mode {
+	case ChunksOutputMode, RawOutputMode:
+		interval := time.Duration(options.ChunkInterval) * time.Millisecond
+		pumpChunks(r, options.ChunkSize, interval, options.Mode == RawOutputMode, lineConsumer)
+	default:
+		pumpLines(r, options.MaxLineLength, lineConsumer)
 	}
 }
 
@@ -0,0 +1,198 @@
+//
+// Copyright (c) 2012-2017 Codenvy, S.A.
+// All rights reserved. This program and the accompanying materials
+// are made available under the terms of the Eclipse Public License v1.0
+// which accompanies this distribution, and is available at
+// http://www.eclipse.org/legal/epl-v10.html
+//
+// Contributors:
+//   Codenvy, S.A. - initial API and implementation
+//
+
+package process
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultReadinessTimeout is how much time process has to become ready
+	// if its readiness probe doesn't configure own timeout.
+	DefaultReadinessTimeout = time.Minute
+)
+
+var (
+	// how often port and url of readiness probe are checked
+	readinessPollPeriod = 500 * time.Millisecond
+)
+
+// ReadinessProbe defines the criteria of the process readiness.
+// The process is considered ready as soon as one of the configured criteria is met.
+type ReadinessProbe struct {
+	// The regexp matched against each stdout and stderr line of the process.
+	Pattern string `json:"pattern,omitempty"`
+
+	// The local TCP port which accepts connections when the process is ready.
+	Port int `json:"port,omitempty"`
+
+	// The HTTP URL which responds with 2xx status code when the process is ready.
+	URL string `json:"url,omitempty"`
+
+	// The time in seconds the process has to become ready,
+	// if zero then DefaultReadinessTimeout is used.
+	Timeout int `json:"timeout,omitempty"`
+}
+
+// Validate checks whether the probe is valid and has at least one criterion.
+func (probe *ReadinessProbe) Validate() error {
+	if probe.Pattern == "" && probe.Port == 0 && probe.URL == "" {
+		return errors.New("Readiness probe requires at least one of 'pattern', 'port', 'url'")
+	}
+	if _, err := regexp.Compile(probe.Pattern); err != nil {
+		return fmt.Errorf("Readiness pattern is not valid. %s", err)
+	}
+	if probe.Port < 0 || probe.Port > 65535 {
+		return fmt.Errorf("Readiness port '%d' is not valid", probe.Port)
+	}
+	if probe.URL != "" {
+		u, err := url.Parse(probe.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("Readiness url '%s' is not valid http url", probe.URL)
+		}
+	}
+	if probe.Timeout < 0 {
+		return errors.New("Readiness timeout must be >= 0")
+	}
+	return nil
+}
+
+// CheckReadinessOutput checks whether the probe may be used along with the output options.
+// The pattern is matched against lines, so it can't be used unless the output is split by lines.
+func CheckReadinessOutput(probe *ReadinessProbe, output *OutputOptions) error {
+	if probe == nil || probe.Pattern == "" {
+		return nil
+	}
+	if mode := output.withDefaults().Mode; mode != LinesOutputMode {
+		return fmt.Errorf("Readiness pattern can't be used in '%s' output mode", mode)
+	}
+	return nil
+}
+
+// Checks readiness of the process by its probe and publishes
+// either process_ready or process_not_ready event once.
+type readinessChecker struct {
+	process *MachineProcess
+	probe   ReadinessProbe
+	pattern *regexp.Regexp
+	timer   *time.Timer
+	once    sync.Once
+	done    chan bool
+}
+
+// The probe must be valid.
+func newReadinessChecker(p *MachineProcess, probe ReadinessProbe) *readinessChecker {
+	checker := &readinessChecker{
+		process: p,
+		probe:   probe,
+		done:    make(chan bool),
+	}
+	if probe.Pattern != "" {
+		checker.pattern = regexp.MustCompile(probe.Pattern)
+	}
+	return checker
+}
+
+// Starts the readiness timeout and polling of the port and url if they are configured.
+func (rc *readinessChecker) start() {
+	timeout := DefaultReadinessTimeout
+	if rc.probe.Timeout > 0 {
+		timeout = time.Duration(rc.probe.Timeout) * time.Second
+	}
+	rc.timer = time.AfterFunc(timeout, func() {
+		rc.finish(false, fmt.Sprintf("Process is not ready in %s", timeout))
+	})
+	if rc.probe.Port != 0 || rc.probe.URL != "" {
+		go rc.poll()
+	}
+}
+
+// Checks whether the line printed by the process matches readiness pattern.
+func (rc *readinessChecker) checkLine(line string) {
+	if rc.pattern != nil && rc.pattern.MatchString(line) {
+		rc.finish(true, fmt.Sprintf("Output matched '%s'", rc.probe.Pattern))
+	}
+}
+
+// Publishes process_not_ready event if the process died before it became ready.
+func (rc *readinessChecker) stop() {
+	rc.finish(false, "Process died before it became ready")
+}
+
+func (rc *readinessChecker) poll() {
+	ticker := time.NewTicker(readinessPollPeriod)
+	defer ticker.Stop()
+	for {
+		if rc.probe.Port != 0 && portAccepts(rc.probe.Port) {
+			rc.finish(true, fmt.Sprintf("Port %d accepts connections", rc.probe.Port))
+		} else if rc.probe.URL != "" && urlResponds(rc.probe.URL) {
+			rc.finish(true, fmt.Sprintf("Url '%s' responded with 2xx", rc.probe.URL))
+		}
+		select {
+		case <-rc.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stops the checks without publishing any event, used when the process is restarted.
+func (rc *readinessChecker) cancel() {
+	rc.once.Do(func() {
+		close(rc.done)
+		if rc.timer != nil {
+			rc.timer.Stop()
+		}
+	})
+}
+
+func (rc *readinessChecker) finish(ready bool, text string) {
+	rc.once.Do(func() {
+		close(rc.done)
+		if rc.timer != nil {
+			rc.timer.Stop()
+		}
+		p := rc.process
+		p.mutex.Lock()
+		p.Ready = ready
+		event := newReadinessEvent(*p, ready, text)
+		p.mutex.Unlock()
+		p.notifySubs(event, StatusBit)
+	})
+}
+
+func portAccepts(port int) bool {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort("localhost", strconv.Itoa(port)), time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+func urlResponds(rawURL string) bool {
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get(rawURL)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode >= 200 && resp.StatusCode < 300
+}
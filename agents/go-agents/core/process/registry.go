@@ -0,0 +1,165 @@
+//
+// Copyright (c) 2012-2017 Codenvy, S.A.
+// All rights reserved. This program and the accompanying materials
+// are made available under the terms of the Eclipse Public License v1.0
+// which accompanies this distribution, and is available at
+// http://www.eclipse.org/legal/epl-v10.html
+//
+// Contributors:
+//   Codenvy, S.A. - initial API and implementation
+//
+
+package process
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// RegistryFilename is the name of the file in logs dir which keeps the processes table
+	RegistryFilename = "processes.json"
+)
+
+var (
+	// whether processes are persisted to the registry file
+	registryEnabled bool
+
+	// serializes writes to the registry file
+	registryMutex sync.Mutex
+)
+
+// registryRecord is an on-disk representation of a MachineProcess.
+type registryRecord struct {
+	MachineProcess
+	StartTime time.Time `json:"startTime"`
+	DeathTime time.Time `json:"deathTime"`
+
+	// The base dir of the file store the process logs are kept in,
+	// empty if the logs are not kept in files so they are lost on restart.
+	LogsDir string `json:"logsDir,omitempty"`
+}
+
+// EnableRegistry enables persisting of the processes table into
+// the registry file located in the logs dir. The registry is updated each time
+// when a process is started, dies or is cleaned up.
+func EnableRegistry() error {
+	if logsDir == "" {
+		return errors.New("Process registry requires logs dir to be configured")
+	}
+	if err := os.MkdirAll(logsDir, os.ModePerm); err != nil {
+		return err
+	}
+	registryEnabled = true
+	return nil
+}
+
+// RestoreProcesses loads the processes persisted in the registry file.
+// Processes which were alive when the registry was written
+// are marked as dead with LostReason, as the agent lost control over them.
+// Pids of the new processes continue from the highest restored pid.
+// Restored processes read their logs from the dir they were written to,
+// processes which logs were not kept in files are restored without logs.
+// If registry file doesn't exist nothing is restored.
+func RestoreProcesses() error {
+	content, err := ioutil.ReadFile(registryPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	records := []*registryRecord{}
+	if err := json.Unmarshal(content, &records); err != nil {
+		return err
+	}
+
+	stores := make(map[string]LogStore)
+	processes.Lock()
+	for _, record := range records {
+		p := record.MachineProcess
+		p.mutex = &sync.RWMutex{}
+		// chains are not persisted, so restored processes don't refer to them
+		p.ParentChain = 0
+		if record.LogsDir != "" {
+			if _, ok := stores[record.LogsDir]; !ok {
+				stores[record.LogsDir] = NewFileLogStore(record.LogsDir, logsDistributor)
+			}
+			p.logs = stores[record.LogsDir]
+		}
+		p.startTime = record.StartTime
+		p.deathTime = record.DeathTime
+		if p.Alive {
+			p.Alive = false
+			p.Reason = LostReason
+			p.deathTime = time.Now()
+		}
+		processes.items[p.Pid] = &p
+		if p.Pid > prevPid {
+			prevPid = p.Pid
+		}
+	}
+	processes.Unlock()
+
+	log.Printf("Restored %d processes from the registry", len(records))
+	persistProcesses()
+	return nil
+}
+
+// Writes all the processes into the registry file if registry is enabled.
+func persistProcesses() {
+	if !registryEnabled {
+		return
+	}
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	processes.RLock()
+	records := make([]*registryRecord, 0, len(processes.items))
+	for _, p := range processes.items {
+		p.mutex.RLock()
+		record := &registryRecord{
+			MachineProcess: *p,
+			StartTime:      p.startTime,
+			DeathTime:      p.deathTime,
+		}
+		// restored processes are never started again, so env isn't needed,
+		// while it often contains secrets which must not be kept on disk
+		record.Env = nil
+		if store, ok := p.logs.(*FileLogStore); ok {
+			record.LogsDir = store.baseDir
+		}
+		records = append(records, record)
+		p.mutex.RUnlock()
+	}
+	processes.RUnlock()
+
+	content, err := json.Marshal(records)
+	if err != nil {
+		log.Printf("Couldn't encode processes registry. %s", err)
+		return
+	}
+
+	// write to the temporary file first, so the registry is never left half written
+	// the file is readable only by the agent's user, as commands may contain secrets too.
+	// The temporary file left by the previous agent is removed first, as writing
+	// into the existing file doesn't change its mode
+	tmpPath := registryPath() + ".tmp"
+	os.Remove(tmpPath)
+	if err := ioutil.WriteFile(tmpPath, content, 0600); err != nil {
+		log.Printf("Couldn't write processes registry file '%s'. %s", tmpPath, err)
+		return
+	}
+	if err := os.Rename(tmpPath, registryPath()); err != nil {
+		log.Printf("Couldn't replace processes registry file. %s", err)
+	}
+}
+
+func registryPath() string {
+	return logsDir + string(os.PathSeparator) + RegistryFilename
+}
@@ -0,0 +1,133 @@
+//
+// Copyright (c) 2012-2017 Codenvy, S.A.
+// All rights reserved. This program and the accompanying materials
+// are made available under the terms of the Eclipse Public License v1.0
+// which accompanies this distribution, and is available at
+// http://www.eclipse.org/legal/epl-v10.html
+//
+// Contributors:
+//   Codenvy, S.A. - initial API and implementation
+//
+
+package process
+
+import (
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRestoresPersistedProcesses(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "exec-agent-registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	SetLogsDir(dir)
+	defer SetLogsDir("")
+	if err := EnableRegistry(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { registryEnabled = false }()
+
+	// use separate processes table, so processes of other tests are not persisted
+	processes.Lock()
+	original := processes.items
+	processes.items = make(map[uint64]*MachineProcess)
+	processes.Unlock()
+	defer func() {
+		processes.Lock()
+		processes.items = original
+		processes.Unlock()
+	}()
+	originalPrevPid := prevPid
+	defer func() { prevPid = originalPrevPid }()
+
+	dead := &MachineProcess{
+		Pid:         1001,
+		Name:        "dead",
+		CommandLine: "echo test",
+		Env:         map[string]string{"SECRET_TOKEN": "secret-value"},
+		ExitCode:    0,
+		Reason:      ExitedReason,
+		mutex:       &sync.RWMutex{},
+		startTime:   time.Now().Add(-time.Minute),
+		deathTime:   time.Now(),
+		logs:        logStore,
+	}
+	alive := &MachineProcess{
+		Pid:         1002,
+		Name:        "alive",
+		CommandLine: "sleep 10",
+		Alive:       true,
+		ExitCode:    -1,
+		mutex:       &sync.RWMutex{},
+		startTime:   time.Now(),
+		logs:        NewMemoryLogStore(10),
+	}
+	processes.Lock()
+	processes.items[dead.Pid] = dead
+	processes.items[alive.Pid] = alive
+	processes.Unlock()
+	persistProcesses()
+
+	info, err := os.Stat(registryPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("Expected registry file to have mode 0600, but it is %o", info.Mode().Perm())
+	}
+	content, err := ioutil.ReadFile(registryPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "secret-value") {
+		t.Fatalf("Expected process env not to be persisted, but registry is %s", content)
+	}
+
+	// simulate agent restart
+	processes.Lock()
+	delete(processes.items, dead.Pid)
+	delete(processes.items, alive.Pid)
+	processes.Unlock()
+	prevPid = 0
+	SetLogStore(NewMemoryLogStore(10))
+
+	if err := RestoreProcesses(); err != nil {
+		t.Fatal(err)
+	}
+
+	restoredDead, err := Get(dead.Pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restoredDead.Name != dead.Name || restoredDead.Reason != ExitedReason {
+		t.Fatalf("Expected restored process to be the same as persisted, but got %v", restoredDead)
+	}
+	restoredAlive, err := Get(alive.Pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restoredAlive.Alive || restoredAlive.Reason != LostReason {
+		t.Fatalf("Expected restored alive process to be lost, but got %v", restoredAlive)
+	}
+	if prevPid != alive.Pid {
+		t.Fatalf("Expected pids to continue from %d, but prevPid is %d", alive.Pid, prevPid)
+	}
+
+	// logs are read from where they were written regardless of the configured store
+	if store, ok := processes.items[dead.Pid].logs.(*FileLogStore); !ok || store.baseDir != dir {
+		t.Fatalf("Expected restored process to keep its logs in '%s', but got %v", dir, processes.items[dead.Pid].logs)
+	}
+	if logs := processes.items[alive.Pid].logs; logs != nil {
+		t.Fatalf("Expected process with memory logs to be restored without logs, but got %v", logs)
+	}
+	if _, err := SearchLogs(SearchQuery{Pattern: regexp.MustCompile("test")}); err != nil {
+		t.Fatalf("Expected logs search to skip restored processes without logs, but got %s", err)
+	}
+}
@@ -0,0 +1,162 @@
+//
+// Copyright (c) 2012-2017 Codenvy, S.A.
+// All rights reserved. This program and the accompanying materials
+// are made available under the terms of the Eclipse Public License v1.0
+// which accompanies this distribution, and is available at
+// http://www.eclipse.org/legal/epl-v10.html
+//
+// Contributors:
+//   Codenvy, S.A. - initial API and implementation
+//
+
+package process
+
+import (
+	"log"
+	"time"
+)
+
+// Restart policies of a process.
+const (
+	// RestartNever means that process is never restarted.
+	RestartNever = "never"
+	// RestartOnFailure means that process is restarted if it exits with non zero code or is signaled.
+	RestartOnFailure = "on-failure"
+	// RestartAlways means that process is restarted regardless of its exit code.
+	RestartAlways = "always"
+
+	// DefaultRestartBackoff is the delay before the first restart
+	// of the process which doesn't configure its own backoff.
+	DefaultRestartBackoff = time.Second
+	// MaxRestartBackoff is the max delay between restarts.
+	MaxRestartBackoff = time.Minute
+)
+
+// Schedules restart of the process if its restart policy requires it.
+// Returns true if the restart is scheduled, the process stays alive
+// and keeps its subscribers until it is restarted.
+func (process *MachineProcess) scheduleRestart(exitCode int, signal string) bool {
+	process.mutex.Lock()
+	if !process.needsRestart(exitCode, signal) {
+		process.mutex.Unlock()
+		return false
+	}
+	delay := process.restartDelay()
+	process.command = nil
+	process.pumper = nil
+	process.stdin = nil
+	process.lastExitCode = exitCode
+	process.lastSignal = signal
+	process.restartTimer = time.AfterFunc(delay, process.restart)
+	event := newRestartingEvent(*process, exitCode, signal, delay)
+	process.mutex.Unlock()
+
+	process.notifySubs(event, StatusBit)
+	return true
+}
+
+// Starts the native process again, the process dies
+// if it was stopped while waiting for restart or if restart failed.
+func (process *MachineProcess) restart() {
+	process.mutex.Lock()
+	process.restartTimer = nil
+	exitCode, signal := process.lastExitCode, process.lastSignal
+	if process.stopped {
+		process.mutex.Unlock()
+		process.die(exitCode, signal)
+		return
+	}
+
+	cmd, stdin, pumper, err := startCommand(*process)
+	if err != nil {
+		process.mutex.Unlock()
+		log.Printf("Couldn't restart process '%d'. %s", process.Pid, err)
+		process.die(exitCode, signal)
+		return
+	}
+	process.command = cmd
+	process.stdin = stdin
+	process.pumper = pumper
+	process.NativePid = cmd.Process.Pid
+	process.RestartCount++
+	if process.logs != nil {
+		pumper.AddConsumer(newLogsStoreWriter(process.logs, process))
+	}
+	pumper.AddConsumer(process)
+
+	// the new run is checked for readiness again
+	previousReadiness := process.readiness
+	if process.Readiness != nil {
+		process.Ready = false
+		process.readiness = newReadinessChecker(process, *process.Readiness)
+	}
+	readiness := process.readiness
+	startedEvent := newStartedEvent(*process)
+	process.mutex.Unlock()
+	persistProcesses()
+
+	// cancelled without the lock, as the checker takes it when it finishes
+	if previousReadiness != nil {
+		previousReadiness.cancel()
+	}
+	go func() {
+		process.notifySubs(startedEvent, StatusBit)
+		if readiness != nil {
+			readiness.start()
+		}
+		pumper.Pump()
+	}()
+}
+
+// Marks the process as stopped, so it won't be restarted anymore.
+// Returns the native pid of the running process and true, or false if the process
+// was waiting for restart, in this case the restart is cancelled and the process dies,
+// or if its native process has already exited and the process is about to die.
+func (process *MachineProcess) stop() (int, bool) {
+	process.mutex.Lock()
+	process.stopped = true
+	timer := process.restartTimer
+	nativePid := process.NativePid
+	running := process.command != nil
+	process.mutex.Unlock()
+
+	if timer != nil && timer.Stop() {
+		go process.restart()
+		return 0, false
+	}
+	return nativePid, running
+}
+
+// Checks whether the process should be restarted after its native process exited.
+func (process *MachineProcess) needsRestart(exitCode int, signal string) bool {
+	if process.stopped {
+		return false
+	}
+	if process.MaxRetries > 0 && process.RestartCount >= process.MaxRetries {
+		return false
+	}
+	switch process.RestartPolicy {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return exitCode != 0 || signal != ""
+	default:
+		return false
+	}
+}
+
+// Computes the delay before the next restart, the delay is doubled
+// for each restart starting from the configured backoff until MaxRestartBackoff reached.
+func (process *MachineProcess) restartDelay() time.Duration {
+	delay := DefaultRestartBackoff
+	if process.RestartBackoff > 0 {
+		delay = time.Duration(process.RestartBackoff) * time.Millisecond
+	}
+	for i := 0; i < process.RestartCount && delay < MaxRestartBackoff; i++ {
+		delay *= 2
+	}
+	if delay > MaxRestartBackoff {
+		delay = MaxRestartBackoff
+	}
+	return delay
+}
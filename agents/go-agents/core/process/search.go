@@ -0,0 +1,219 @@
+//
+// Copyright (c) 2012-2017 Codenvy, S.A.
+// All rights reserved. This program and the accompanying materials
+// are made available under the terms of the Eclipse Public License v1.0
+// which accompanies this distribution, and is available at
+// http://www.eclipse.org/legal/epl-v10.html
+//
+// Contributors:
+//   Codenvy, S.A. - initial API and implementation
+//
+
+package process
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"time"
+)
+
+const (
+	// DefaultSearchLimit is the number of matches returned if the limit is not specified.
+	DefaultSearchLimit = 50
+	// MaxSearchLimit is the max number of matches returned at once.
+	MaxSearchLimit = 1000
+	// MaxSearchContext is the max number of context logs before and after the match.
+	MaxSearchContext = 10
+)
+
+// SearchQuery defines which logs are searched and how the matches are paged.
+type SearchQuery struct {
+	// The pattern the text of the log must match.
+	Pattern *regexp.Regexp
+
+	// The pids of the processes logs of which are searched, empty means all the processes.
+	Pids []uint64
+
+	// If set then only logs of this kind are matched.
+	Kind *LogKind
+
+	// Only logs between [From, Till] are searched, zero Till means now.
+	From time.Time
+	Till time.Time
+
+	// The number of logs before and after the matched log returned along with it.
+	Context int
+
+	// The number of matches to skip and the max number of matches to return,
+	// zero limit means DefaultSearchLimit.
+	Skip  int
+	Limit int
+}
+
+// SearchMatch is the log which matched the search query.
+type SearchMatch struct {
+	Pid  uint64    `json:"pid"`
+	Line int       `json:"line"`
+	Kind LogKind   `json:"kind"`
+	Time time.Time `json:"time"`
+	Text string    `json:"text"`
+
+	// Context logs which appeared right before and after the matched log.
+	Before []*LogMessage `json:"before,omitempty"`
+	After  []*LogMessage `json:"after,omitempty"`
+}
+
+// SearchResult is a page of the matches ordered by pid and then by line.
+type SearchResult struct {
+	Matches []*SearchMatch `json:"matches"`
+
+	// Whether there are more matches after the returned ones.
+	HasMore bool `json:"hasMore"`
+}
+
+// Validate checks whether the search query is valid.
+func (query *SearchQuery) Validate() error {
+	if query.Pattern == nil {
+		return errors.New("Search pattern required")
+	}
+	if query.Context < 0 || query.Context > MaxSearchContext {
+		return fmt.Errorf("Required 'context' to be in range [0, %d]", MaxSearchContext)
+	}
+	if query.Skip < 0 {
+		return errors.New("Required 'skip' to be >= 0")
+	}
+	if query.Limit < 0 || query.Limit > MaxSearchLimit {
+		return fmt.Errorf("Required 'limit' to be in range [0, %d]", MaxSearchLimit)
+	}
+	return nil
+}
+
+// SearchLogs searches logs of the processes which match the query,
+// processes are searched in the order of their pids.
+// If any of the query processes doesn't exist error of type NoProcessError is returned.
+func SearchLogs(query SearchQuery) (SearchResult, error) {
+	result := SearchResult{Matches: []*SearchMatch{}}
+	if err := query.Validate(); err != nil {
+		return result, err
+	}
+	if query.Limit == 0 {
+		query.Limit = DefaultSearchLimit
+	}
+	if query.Till.IsZero() {
+		query.Till = time.Now()
+	}
+	targets, err := searchTargets(query.Pids)
+	if err != nil {
+		return result, err
+	}
+
+	skipped := 0
+	for _, p := range targets {
+		logs, err := p.logStore()
+		if err != nil {
+			// the process doesn't have logs
+			continue
+		}
+		if err := query.search(p.Pid, logs, &result, &skipped); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return result, err
+		}
+		if result.HasMore {
+			break
+		}
+	}
+	return result, nil
+}
+
+// Searches the logs of the process appending the matches to the result.
+func (query *SearchQuery) search(pid uint64, logs LogStore, result *SearchResult, skipped *int) error {
+	before := make([]*LogMessage, 0, query.Context)
+	var pending []*SearchMatch
+	return logs.Scan(pid, query.From, query.Till, func(line int, message *LogMessage) bool {
+		// the message is the after context of the previous matches
+		for len(pending) != 0 && len(pending[0].After) == query.Context {
+			pending = pending[1:]
+		}
+		for _, match := range pending {
+			match.After = append(match.After, message)
+		}
+
+		if query.matches(message) {
+			if *skipped < query.Skip {
+				*skipped++
+			} else if len(result.Matches) < query.Limit {
+				match := &SearchMatch{
+					Pid:  pid,
+					Line: line,
+					Kind: message.Kind,
+					Time: message.Time,
+					Text: message.Text,
+				}
+				if query.Context > 0 {
+					match.Before = append([]*LogMessage{}, before...)
+					pending = append(pending, match)
+				}
+				result.Matches = append(result.Matches, match)
+			} else {
+				result.HasMore = true
+			}
+		}
+		if result.HasMore && (len(pending) == 0 || len(pending[len(pending)-1].After) == query.Context) {
+			return false
+		}
+
+		if query.Context > 0 {
+			if len(before) == query.Context {
+				before = before[1:]
+			}
+			before = append(before, message)
+		}
+		return true
+	})
+}
+
+func (query *SearchQuery) matches(message *LogMessage) bool {
+	if query.Kind != nil && message.Kind != *query.Kind {
+		return false
+	}
+	return query.Pattern.MatchString(message.Text)
+}
+
+// Returns the processes with given pids or all the processes if no pids given, sorted by pid.
+func searchTargets(pids []uint64) ([]*MachineProcess, error) {
+	var targets []*MachineProcess
+	if len(pids) == 0 {
+		processes.RLock()
+		for _, p := range processes.items {
+			targets = append(targets, p)
+		}
+		processes.RUnlock()
+	} else {
+		unique := make(map[uint64]bool)
+		for _, pid := range pids {
+			if unique[pid] {
+				continue
+			}
+			unique[pid] = true
+			p, ok := directGet(pid)
+			if !ok {
+				return nil, noProcess(pid)
+			}
+			targets = append(targets, p)
+		}
+	}
+	sort.Sort(byPid(targets))
+	return targets, nil
+}
+
+// Sorts processes by pid.
+type byPid []*MachineProcess
+
+func (p byPid) Len() int           { return len(p) }
+func (p byPid) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p byPid) Less(i, j int) bool { return p[i].Pid < p[j].Pid }
@@ -0,0 +1,52 @@
+//
+// Copyright (c) 2012-2017 Codenvy, S.A.
+// All rights reserved. This program and the accompanying materials
+// are made available under the terms of the Eclipse Public License v1.0
+// which accompanies this distribution, and is available at
+// http://www.eclipse.org/legal/epl-v10.html
+//
+// Contributors:
+//   Codenvy, S.A. - initial API and implementation
+//
+
+package process
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+)
+
+// The signals which may be sent to a process group, mapped by their names without 'SIG' prefix.
+var signals = map[string]syscall.Signal{
+	"TERM": syscall.SIGTERM,
+	"INT":  syscall.SIGINT,
+	"HUP":  syscall.SIGHUP,
+	"QUIT": syscall.SIGQUIT,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+	"STOP": syscall.SIGSTOP,
+	"CONT": syscall.SIGCONT,
+	"KILL": syscall.SIGKILL,
+}
+
+// ParseSignal converts signal name e.g. 'TERM' or 'SIGTERM' into the signal.
+// The name is case insensitive, an error is returned if the signal is not supported.
+func ParseSignal(name string) (syscall.Signal, error) {
+	upper := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(name)), "SIG")
+	if sig, ok := signals[upper]; ok {
+		return sig, nil
+	}
+	return 0, fmt.Errorf("Signal '%s' is not supported", name)
+}
+
+// SignalName returns the name of the signal without 'SIG' prefix e.g. 'TERM'.
+// If signal is not one of the supported signals then its number is returned.
+func SignalName(sig syscall.Signal) string {
+	for name, s := range signals {
+		if s == sig {
+			return name
+		}
+	}
+	return fmt.Sprintf("%d", int(sig))
+}
@@ -0,0 +1,139 @@
+//
+// Copyright (c) 2012-2017 Codenvy, S.A.
+// All rights reserved. This program and the accompanying materials
+// are made available under the terms of the Eclipse Public License v1.0
+// which accompanies this distribution, and is available at
+// http://www.eclipse.org/legal/epl-v10.html
+//
+// Contributors:
+//   Codenvy, S.A. - initial API and implementation
+//
+
+package process
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+const (
+	// DefaultStatsPeriod defines how often process_stats events are published
+	DefaultStatsPeriod = 5 * time.Second
+)
+
+var (
+	// how often process_stats events are published to subscribers with StatsBit
+	statsPeriod = DefaultStatsPeriod
+)
+
+// SetStatsPeriod changes the default period of process_stats events publishing.
+func SetStatsPeriod(period time.Duration) {
+	if period > 0 {
+		statsPeriod = period
+	}
+}
+
+// Stats describes resources consumed by all the native processes
+// of the process session, including the process itself and all its descendants.
+type Stats struct {
+	Time      time.Time `json:"time"`
+	Pid       uint64    `json:"pid"`
+	NativePid int       `json:"nativePid"`
+
+	// User and system CPU time consumed by the session processes in milliseconds.
+	UserTime   uint64 `json:"userTime"`
+	SystemTime uint64 `json:"systemTime"`
+	CPUTime    uint64 `json:"cpuTime"`
+
+	// Resident set size of the session processes in bytes.
+	RSS int64 `json:"rss"`
+
+	// The number of threads of the session processes.
+	Threads int `json:"threads"`
+
+	// The number of open file descriptors of the session processes.
+	OpenFiles int `json:"openFiles"`
+
+	// Native pids of the session processes except the process itself.
+	Children []int `json:"children"`
+}
+
+// GetStats reads resource usage of the alive process from /proc.
+// If process doesn't exist error of type NoProcessError is returned,
+// if process is not alive error of type NotAliveError is returned.
+func GetStats(pid uint64) (*Stats, error) {
+	p, err := aliveProcess(pid)
+	if err != nil {
+		return nil, err
+	}
+	p.mutex.RLock()
+	nativePid := p.NativePid
+	p.mutex.RUnlock()
+	return readStats(pid, nativePid)
+}
+
+func readStats(pid uint64, nativePid int) (*Stats, error) {
+	// the process is started with setsid so session id is equal to its native pid
+	procStats, err := sessionProcStats(nativePid)
+	if err != nil {
+		return nil, err
+	}
+	stats := &Stats{
+		Time:      time.Now(),
+		Pid:       pid,
+		NativePid: nativePid,
+		Children:  []int{},
+	}
+	pageSize := int64(os.Getpagesize())
+	for _, ps := range procStats {
+		stats.UserTime += ps.utime * 1000 / clockTicksPerSecond
+		stats.SystemTime += ps.stime * 1000 / clockTicksPerSecond
+		stats.RSS += ps.rss * pageSize
+		stats.Threads += ps.threads
+		stats.OpenFiles += countOpenFiles(ps.pid)
+		if ps.pid != nativePid {
+			stats.Children = append(stats.Children, ps.pid)
+		}
+	}
+	stats.CPUTime = stats.UserTime + stats.SystemTime
+	return stats, nil
+}
+
+// Publishes process_stats events to the subscribers interested in them
+// until the process dies. Stats are read only if there is such subscriber.
+func (process *MachineProcess) publishStatsPeriodically(done chan bool) {
+	ticker := time.NewTicker(statsPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if !process.hasSubscriber(StatsBit) {
+				continue
+			}
+			process.mutex.RLock()
+			nativePid := process.NativePid
+			process.mutex.RUnlock()
+			stats, err := readStats(process.Pid, nativePid)
+			if err != nil {
+				log.Printf("Couldn't read stats of process '%d'. %s", process.Pid, err)
+				continue
+			}
+			process.notifySubs(&StatsEvent{*stats}, StatsBit)
+		}
+	}
+}
+
+// Checks whether there is at least one subscriber with given type bit in its mask.
+func (process *MachineProcess) hasSubscriber(typeBit uint64) bool {
+	process.mutex.RLock()
+	defer process.mutex.RUnlock()
+	for _, sub := range process.subs {
+		if sub.Mask&typeBit == typeBit {
+			return true
+		}
+	}
+	return false
+}
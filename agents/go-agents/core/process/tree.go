@@ -0,0 +1,134 @@
+//
+// Copyright (c) 2012-2017 Codenvy, S.A.
+// All rights reserved. This program and the accompanying materials
+// are made available under the terms of the Eclipse Public License v1.0
+// which accompanies this distribution, and is available at
+// http://www.eclipse.org/legal/epl-v10.html
+//
+// Contributors:
+//   Codenvy, S.A. - initial API and implementation
+//
+
+package process
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+)
+
+// TreeNode is a native process in the live descendants tree of the process.
+type TreeNode struct {
+	NativePid int         `json:"nativePid"`
+	Ppid      int         `json:"ppid"`
+	Argv      []string    `json:"argv"`
+	State     string      `json:"state"`
+	StartTime time.Time   `json:"startTime"`
+	Children  []*TreeNode `json:"children"`
+}
+
+// NotInSessionError is returned when native process which is the target
+// of an action doesn't belong to the session of the process.
+type NotInSessionError struct {
+	error
+	Pid       uint64
+	NativePid int
+}
+
+// GetTree walks /proc and returns the live descendants tree of the process,
+// the root of the tree is the native process started by the agent.
+// Members of the process session or group which are not descendants of the root anymore,
+// e.g. daemonized ones which are reparented to init, are attached to the root.
+// If process doesn't exist error of type NoProcessError is returned,
+// if process is not alive error of type NotAliveError is returned.
+func GetTree(pid uint64) (*TreeNode, error) {
+	p, err := aliveProcess(pid)
+	if err != nil {
+		return nil, err
+	}
+	p.mutex.RLock()
+	nativePid := p.NativePid
+	p.mutex.RUnlock()
+
+	stats, err := allProcStats()
+	if err != nil {
+		return nil, err
+	}
+	bootTime, err := readBootTime()
+	if err != nil {
+		return nil, err
+	}
+
+	// the process is started with setsid so session id and group id are equal to its native pid
+	children := make(map[int][]*procStat)
+	members := make(map[int]bool)
+	var rootStat *procStat
+	for _, stat := range stats {
+		if stat.pid == nativePid {
+			rootStat = stat
+		}
+		if stat.session == nativePid || stat.pgrp == nativePid {
+			members[stat.pid] = true
+		}
+		children[stat.ppid] = append(children[stat.ppid], stat)
+	}
+	if rootStat == nil {
+		return nil, notAlive(pid)
+	}
+
+	visited := make(map[int]bool)
+	root := newTreeNode(rootStat, children, bootTime, visited)
+	for _, stat := range stats {
+		// members whose parent is a member too are attached along with their parent
+		if members[stat.pid] && !visited[stat.pid] && !members[stat.ppid] {
+			root.Children = append(root.Children, newTreeNode(stat, children, bootTime, visited))
+		}
+	}
+	return root, nil
+}
+
+// SignalNative sends given signal to the single native process
+// which belongs to the session of the process, e.g. to one of its descendants.
+// If process doesn't exist error of type NoProcessError is returned,
+// if process is not alive error of type NotAliveError is returned,
+// if native process is not in the process session error of type NotInSessionError is returned.
+func SignalNative(pid uint64, nativePid int, sig syscall.Signal) error {
+	p, err := aliveProcess(pid)
+	if err != nil {
+		return err
+	}
+	p.mutex.RLock()
+	sid := p.NativePid
+	p.mutex.RUnlock()
+
+	// the process is started with setsid so session id is equal to its native pid
+	stat, err := readProcStat(nativePid)
+	if err != nil || stat.session != sid {
+		return notInSession(pid, nativePid)
+	}
+	return syscall.Kill(nativePid, sig)
+}
+
+func newTreeNode(stat *procStat, children map[int][]*procStat, bootTime time.Time, visited map[int]bool) *TreeNode {
+	visited[stat.pid] = true
+	node := &TreeNode{
+		NativePid: stat.pid,
+		Ppid:      stat.ppid,
+		Argv:      readCmdline(stat.pid),
+		State:     stat.state,
+		StartTime: bootTime.Add(time.Duration(stat.startTime) * time.Second / clockTicksPerSecond),
+		Children:  []*TreeNode{},
+	}
+	for _, child := range children[stat.pid] {
+		node.Children = append(node.Children, newTreeNode(child, children, bootTime, visited))
+	}
+	return node
+}
+
+func notInSession(pid uint64, nativePid int) *NotInSessionError {
+	return &NotInSessionError{
+		error:     fmt.Errorf("Native process '%d' doesn't belong to the session of process with id '%d'", nativePid, pid),
+		Pid:       pid,
+		NativePid: nativePid,
+	}
+}
@@ -0,0 +1,161 @@
+//
+// Copyright (c) 2012-2017 Codenvy, S.A.
+// All rights reserved. This program and the accompanying materials
+// are made available under the terms of the Eclipse Public License v1.0
+// which accompanies this distribution, and is available at
+// http://www.eclipse.org/legal/epl-v10.html
+//
+// Contributors:
+//   Codenvy, S.A. - initial API and implementation
+//
+
+package process
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/eclipse/che-lib/pty"
+)
+
+const (
+	// DefaultTtyCols is the width of the process terminal unless another one is specified.
+	DefaultTtyCols = 80
+
+	// DefaultTtyRows is the height of the process terminal unless another one is specified.
+	DefaultTtyRows = 24
+
+	// the max value of the terminal window size
+	maxTtySize = 65535
+
+	// the value of TERM environment variable of the process terminal
+	ttyTerm = "xterm-256color"
+
+	// the end of transmission character, the terminal reads EOF when it's written
+	eot = 0x04
+)
+
+// CheckTtySize checks whether the window size of the process terminal is valid,
+// zero values mean default sizes.
+func CheckTtySize(cols int, rows int) error {
+	if cols < 0 || cols > maxTtySize {
+		return fmt.Errorf("Terminal cols must be in range [0, %d]", maxTtySize)
+	}
+	if rows < 0 || rows > maxTtySize {
+		return fmt.Errorf("Terminal rows must be in range [0, %d]", maxTtySize)
+	}
+	return nil
+}
+
+// Resize changes the window size of the process terminal,
+// the new size is also applied when the process is restarted.
+// If process doesn't exist error of type NoProcessError is returned,
+// if process is not alive error of type NotAliveError is returned.
+func Resize(pid uint64, cols int, rows int) error {
+	if err := CheckTtySize(cols, rows); err != nil {
+		return err
+	}
+	p, ok := directGet(pid)
+	if !ok {
+		return noProcess(pid)
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if !p.Alive {
+		return notAlive(pid)
+	}
+	if !p.Tty {
+		return fmt.Errorf("Process '%d' doesn't have a terminal", pid)
+	}
+	p.Cols = cols
+	p.Rows = rows
+	// the pumper is missing while the process waits for restart
+	if p.pumper == nil || p.pumper.tty == nil {
+		return nil
+	}
+	return setTtySize(p.pumper.tty, cols, rows)
+}
+
+// Starts the command attached to a new pseudo-terminal.
+// Returns the master side of the terminal the output is read from and the input is written to.
+func startTty(cmd *exec.Cmd, cols int, rows int) (*os.File, error) {
+	master, slave, err := pty.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer closeFile(slave)
+	if err := setTtySize(master, cols, rows); err != nil {
+		closeFile(master)
+		return nil, err
+	}
+
+	cmd.Stdin = slave
+	cmd.Stdout = slave
+	cmd.Stderr = slave
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	// the terminal becomes the controlling terminal of the new session
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	if err := cmd.Start(); err != nil {
+		closeFile(master)
+		return nil, err
+	}
+	return master, nil
+}
+
+func setTtySize(tty *os.File, cols int, rows int) error {
+	if cols == 0 {
+		cols = DefaultTtyCols
+	}
+	if rows == 0 {
+		rows = DefaultTtyRows
+	}
+	return pty.Setsize(tty, uint16(rows), uint16(cols))
+}
+
+// Returns the environment of the process terminal, TERM is set unless the process specifies it.
+func ttyEnv(env []string, p MachineProcess) []string {
+	if _, ok := p.Env["TERM"]; ok {
+		return env
+	}
+	if env == nil {
+		env = os.Environ()
+	}
+	return append(env, "TERM="+ttyTerm)
+}
+
+// Reads the output of the process terminal.
+type ttyOutput struct {
+	tty *os.File
+}
+
+// Read returns EOF instead of EIO which is returned when
+// all the processes attached to the terminal closed it.
+func (out *ttyOutput) Read(p []byte) (int, error) {
+	n, err := out.tty.Read(p)
+	if pathErr, ok := err.(*os.PathError); ok && pathErr.Err == syscall.EIO {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// Writes the input of the process terminal.
+type ttyInput struct {
+	tty *os.File
+}
+
+func (in *ttyInput) Write(p []byte) (int, error) {
+	return in.tty.Write(p)
+}
+
+// Close sends the end of transmission, so the process reads EOF,
+// the terminal itself is closed when its output is pumped.
+func (in *ttyInput) Close() error {
+	_, err := in.tty.Write([]byte{eot})
+	return err
+}
@@ -0,0 +1,288 @@
+//
+// Copyright (c) 2012-2017 Codenvy, S.A.
+// All rights reserved. This program and the accompanying materials
+// are made available under the terms of the Eclipse Public License v1.0
+// which accompanies this distribution, and is available at
+// http://www.eclipse.org/legal/epl-v10.html
+//
+// Contributors:
+//   Codenvy, S.A. - initial API and implementation
+//
+
+package process
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// AnyUser allows processes to run as any user when it is in the allowed users list.
+const AnyUser = "*"
+
+// The users and groups are read from these files, as os/user
+// lookups require cgo which the agent is built without.
+const (
+	passwdFile = "/etc/passwd"
+	groupFile  = "/etc/group"
+)
+
+var (
+	// names and uids of the users processes may run as,
+	// if empty then processes run only as the agent's user
+	allowedUsers = map[string]bool{}
+
+	// names and gids of the groups processes may run as in addition
+	// to the groups of their users, if empty then the group of the user can't be overridden
+	allowedGroups = map[string]bool{}
+)
+
+// SetAllowedUsers sets names or uids of the users processes may run as,
+// AnyUser allows all the users.
+func SetAllowedUsers(users []string) {
+	allowed := map[string]bool{}
+	for _, u := range users {
+		if u = strings.TrimSpace(u); u != "" {
+			allowed[u] = true
+		}
+	}
+	allowedUsers = allowed
+}
+
+// SetAllowedGroups sets names or gids of the groups processes may run as
+// in addition to the groups their users belong to, AnyUser allows all the groups.
+func SetAllowedGroups(groups []string) {
+	allowed := map[string]bool{}
+	for _, g := range groups {
+		if g = strings.TrimSpace(g); g != "" {
+			allowed[g] = true
+		}
+	}
+	allowedGroups = allowed
+}
+
+// runAs is the resolved identity of the process.
+type runAs struct {
+	uid    uint32
+	gid    uint32
+	groups []uint32
+	home   string
+	name   string
+}
+
+// CheckUser checks whether the processes may run as the user defined by given spec.
+// The spec is either user name or uid optionally followed by ':' and group name or gid,
+// e.g. 'user', '1000', 'user:group', '1000:1000'.
+func CheckUser(spec string) error {
+	_, err := resolveUser(spec)
+	return err
+}
+
+// Resolves the user spec and checks that the user is allowed.
+func resolveUser(spec string) (*runAs, error) {
+	userPart, groupPart := spec, ""
+	if idx := strings.IndexByte(spec, ':'); idx >= 0 {
+		userPart, groupPart = spec[:idx], spec[idx+1:]
+	}
+	if userPart == "" {
+		return nil, fmt.Errorf("User '%s' is not valid", spec)
+	}
+
+	ra := &runAs{}
+	if u, ok := lookupUser(userPart); ok {
+		ra.uid, ra.gid, ra.home, ra.name = u.uid, u.gid, u.home, u.name
+		ra.groups = supplementaryGroups(u.name, u.gid)
+	} else if uid, convErr := strconv.ParseUint(userPart, 10, 32); convErr == nil {
+		// numeric uid which is not in passwd, the group is the same as uid unless specified
+		ra.uid, ra.gid = uint32(uid), uint32(uid)
+	} else {
+		return nil, fmt.Errorf("User '%s' doesn't exist", userPart)
+	}
+
+	if !isAllowedUser(userPart, ra) {
+		return nil, fmt.Errorf("Running processes as user '%s' is not allowed", userPart)
+	}
+
+	if groupPart != "" {
+		gid, name, err := lookupGroup(groupPart)
+		if err != nil {
+			return nil, err
+		}
+		if !ra.isMemberOf(gid) && !isAllowedGroup(groupPart, gid, name) {
+			return nil, fmt.Errorf("Running processes as group '%s' is not allowed", groupPart)
+		}
+		ra.gid, ra.groups = gid, nil
+	}
+	return ra, nil
+}
+
+func isAllowedUser(userPart string, ra *runAs) bool {
+	return allowedUsers[AnyUser] ||
+		allowedUsers[userPart] ||
+		allowedUsers[strconv.FormatUint(uint64(ra.uid), 10)] ||
+		(ra.name != "" && allowedUsers[ra.name])
+}
+
+func isAllowedGroup(groupPart string, gid uint32, name string) bool {
+	return allowedGroups[AnyUser] ||
+		allowedGroups[groupPart] ||
+		allowedGroups[strconv.FormatUint(uint64(gid), 10)] ||
+		(name != "" && allowedGroups[name])
+}
+
+// Checks whether the gid is the primary or a supplementary group of the user.
+func (ra *runAs) isMemberOf(gid uint32) bool {
+	if ra.gid == gid {
+		return true
+	}
+	for _, g := range ra.groups {
+		if g == gid {
+			return true
+		}
+	}
+	return false
+}
+
+// The entry of passwd file.
+type passwdEntry struct {
+	name string
+	uid  uint32
+	gid  uint32
+	home string
+}
+
+// The entry of group file.
+type groupEntry struct {
+	name    string
+	gid     uint32
+	members []string
+}
+
+// Looks up the user by name or uid in passwd file.
+func lookupUser(nameOrID string) (*passwdEntry, bool) {
+	_, byID := parseID(nameOrID)
+	var found *passwdEntry
+	scanEntries(passwdFile, 7, func(fields []string) bool {
+		uid, uidOk := parseID(fields[2])
+		gid, gidOk := parseID(fields[3])
+		if !uidOk || !gidOk {
+			return false
+		}
+		if (byID && fields[2] == nameOrID) || (!byID && fields[0] == nameOrID) {
+			found = &passwdEntry{name: fields[0], uid: uid, gid: gid, home: fields[5]}
+			return true
+		}
+		return false
+	})
+	return found, found != nil
+}
+
+// Returns the gid and the name of the group, the name is empty if gid is not known.
+func lookupGroup(nameOrID string) (uint32, string, error) {
+	id, byID := parseID(nameOrID)
+	var found *groupEntry
+	scanEntries(groupFile, 4, func(fields []string) bool {
+		if g, ok := parseGroup(fields); ok && ((byID && g.gid == id) || (!byID && g.name == nameOrID)) {
+			found = g
+			return true
+		}
+		return false
+	})
+	if found != nil {
+		return found.gid, found.name, nil
+	}
+	if byID {
+		return id, "", nil
+	}
+	return 0, "", fmt.Errorf("Group '%s' doesn't exist", nameOrID)
+}
+
+// Returns the primary group and the groups which list the user as their member.
+func supplementaryGroups(name string, gid uint32) []uint32 {
+	groups := []uint32{gid}
+	scanEntries(groupFile, 4, func(fields []string) bool {
+		if g, ok := parseGroup(fields); ok && g.gid != gid {
+			for _, member := range g.members {
+				if member == name {
+					groups = append(groups, g.gid)
+					break
+				}
+			}
+		}
+		return false
+	})
+	return groups
+}
+
+func parseGroup(fields []string) (*groupEntry, bool) {
+	gid, ok := parseID(fields[2])
+	if !ok {
+		return nil, false
+	}
+	g := &groupEntry{name: fields[0], gid: gid}
+	if fields[3] != "" {
+		g.members = strings.Split(fields[3], ",")
+	}
+	return g, true
+}
+
+func parseID(value string) (uint32, bool) {
+	id, err := strconv.ParseUint(value, 10, 32)
+	return uint32(id), err == nil
+}
+
+// Calls the function with the colon separated fields of each entry of the file
+// until it returns true. Comments and entries with fewer fields are skipped,
+// not existing file has no entries.
+func scanEntries(path string, fieldsNum int, f func(fields []string) bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ":")
+		if len(fields) >= fieldsNum && f(fields) {
+			return
+		}
+	}
+}
+
+// Returns credential of the resolved user, or nil if the user is the agent's one
+// so the process is started without switching the user.
+func (ra *runAs) credential() *syscall.Credential {
+	if int(ra.uid) == os.Geteuid() && int(ra.gid) == os.Getegid() {
+		return nil
+	}
+	return &syscall.Credential{Uid: ra.uid, Gid: ra.gid, Groups: ra.groups}
+}
+
+// Returns the uid the command is executed as.
+func effectiveUID(cmd *exec.Cmd) int {
+	if cmd.SysProcAttr != nil && cmd.SysProcAttr.Credential != nil {
+		return int(cmd.SysProcAttr.Credential.Uid)
+	}
+	return os.Geteuid()
+}
+
+// Returns environment variables describing the user,
+// empty if the user is not known by name.
+func (ra *runAs) env() map[string]string {
+	if ra.name == "" {
+		return nil
+	}
+	return map[string]string{
+		"HOME":    ra.home,
+		"USER":    ra.name,
+		"LOGNAME": ra.name,
+	}
+}
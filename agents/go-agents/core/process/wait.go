@@ -0,0 +1,74 @@
+//
+// Copyright (c) 2012-2017 Codenvy, S.A.
+// All rights reserved. This program and the accompanying materials
+// are made available under the terms of the Eclipse Public License v1.0
+// which accompanies this distribution, and is available at
+// http://www.eclipse.org/legal/epl-v10.html
+//
+// Contributors:
+//   Codenvy, S.A. - initial API and implementation
+//
+
+package process
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// used to generate unique ids of the waiters subscribers
+	prevWaiterID uint64
+)
+
+// Receives process_died event and signals the waiter about it.
+type deathWaiter struct {
+	died chan bool
+}
+
+func (dw *deathWaiter) Accept(event Event) {
+	if event.Type() != DiedEventType {
+		return
+	}
+	// the waiter needs only one signal and never blocks the publisher
+	select {
+	case dw.died <- true:
+	default:
+	}
+}
+
+// Wait blocks until the process dies or the timeout elapses and returns the process.
+// If the timeout elapses the returned process is still alive,
+// non positive timeout means waiting until the process dies.
+// If process doesn't exist error of type NoProcessError is returned.
+func Wait(pid uint64, timeout time.Duration) (MachineProcess, error) {
+	return WaitOrCancel(pid, timeout, nil)
+}
+
+// WaitOrCancel is like Wait but it also stops waiting when cancel is closed,
+// e.g. when the client waiting for the process disconnects.
+func WaitOrCancel(pid uint64, timeout time.Duration, cancel <-chan struct{}) (MachineProcess, error) {
+	waiter := &deathWaiter{died: make(chan bool, 1)}
+	id := fmt.Sprintf("waiter-%d", atomic.AddUint64(&prevWaiterID, 1))
+	if err := AddSubscriber(pid, Subscriber{ID: id, Mask: StatusBit, Consumer: waiter}); err != nil {
+		// the process is already dead or doesn't exist
+		return Get(pid)
+	}
+
+	var timeoutChan <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutChan = timer.C
+	}
+
+	select {
+	case <-waiter.died:
+	case <-timeoutChan:
+		RemoveSubscriber(pid, id)
+	case <-cancel:
+		RemoveSubscriber(pid, id)
+	}
+	return Get(pid)
+}
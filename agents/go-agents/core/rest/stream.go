@@ -0,0 +1,160 @@
+//
+// Copyright (c) 2012-2017 Codenvy, S.A.
+// All rights reserved. This program and the accompanying materials
+// are made available under the terms of the Eclipse Public License v1.0
+// which accompanies this distribution, and is available at
+// http://www.eclipse.org/legal/epl-v10.html
+//
+// Contributors:
+//   Codenvy, S.A. - initial API and implementation
+//
+
+package rest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+)
+
+// StreamWriter is the writer of the response which is not limited by the server's
+// write timeout, such as streamed response or response which waits for something.
+type StreamWriter interface {
+	http.ResponseWriter
+	http.Flusher
+
+	// Disconnected returns the channel which is closed when the client disconnects.
+	Disconnected() <-chan struct{}
+
+	// Close ends the response, the writer must not be used after it's closed.
+	Close() error
+}
+
+// NewStreamWriter hijacks the connection of the response writer, so the time the response
+// is written is not limited by the server's write timeout, instead each write is limited by it.
+// The body of the response ends when the writer is closed and the connection is closed.
+// If the connection can't be hijacked, e.g. in case of HTTP/2, the response writer is used as is.
+func NewStreamWriter(w http.ResponseWriter, r *http.Request) (StreamWriter, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, errors.New("Streaming is not supported by the response writer")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok || r.ProtoMajor != 1 {
+		return &responseStreamWriter{w, flusher, r.Context().Done()}, nil
+	}
+
+	var writeTimeout time.Duration
+	if server, ok := r.Context().Value(http.ServerContextKey).(*http.Server); ok {
+		writeTimeout = server.WriteTimeout
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	// the request is read, so the read deadline is not needed anymore
+	// while the write deadline is prolonged on each write
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	sw := &connStreamWriter{
+		conn:         conn,
+		rw:           rw,
+		header:       make(http.Header),
+		writeTimeout: writeTimeout,
+		disconnected: make(chan struct{}),
+	}
+	for k, v := range w.Header() {
+		sw.header[k] = v
+	}
+
+	// the client sends nothing else, so reading ends once it disconnects
+	go func() {
+		io.Copy(ioutil.Discard, rw.Reader)
+		close(sw.disconnected)
+	}()
+	return sw, nil
+}
+
+// Writes the response into the original response writer.
+type responseStreamWriter struct {
+	http.ResponseWriter
+	flusher http.Flusher
+	done    <-chan struct{}
+}
+
+func (sw *responseStreamWriter) Flush() { sw.flusher.Flush() }
+
+func (sw *responseStreamWriter) Disconnected() <-chan struct{} { return sw.done }
+
+func (sw *responseStreamWriter) Close() error {
+	sw.flusher.Flush()
+	return nil
+}
+
+// Writes the response straight into the hijacked connection.
+type connStreamWriter struct {
+	conn         net.Conn
+	rw           *bufio.ReadWriter
+	header       http.Header
+	wroteHeader  bool
+	writeTimeout time.Duration
+	disconnected chan struct{}
+}
+
+func (sw *connStreamWriter) Header() http.Header { return sw.header }
+
+func (sw *connStreamWriter) WriteHeader(code int) {
+	if sw.wroteHeader {
+		return
+	}
+	sw.wroteHeader = true
+
+	// the body is not delimited, so it ends with the connection
+	sw.header.Set("Connection", "close")
+	sw.header.Del("Content-Length")
+	if sw.header.Get("Date") == "" {
+		sw.header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	}
+	sw.prolongDeadline()
+	fmt.Fprintf(sw.rw, "HTTP/1.1 %03d %s\r\n", code, http.StatusText(code))
+	sw.header.Write(sw.rw)
+	sw.rw.WriteString("\r\n")
+}
+
+func (sw *connStreamWriter) Write(p []byte) (int, error) {
+	if !sw.wroteHeader {
+		sw.WriteHeader(http.StatusOK)
+	}
+	sw.prolongDeadline()
+	return sw.rw.Write(p)
+}
+
+func (sw *connStreamWriter) Flush() {
+	if !sw.wroteHeader {
+		sw.WriteHeader(http.StatusOK)
+	}
+	sw.prolongDeadline()
+	sw.rw.Flush()
+}
+
+func (sw *connStreamWriter) Disconnected() <-chan struct{} { return sw.disconnected }
+
+func (sw *connStreamWriter) Close() error {
+	sw.Flush()
+	return sw.conn.Close()
+}
+
+func (sw *connStreamWriter) prolongDeadline() {
+	if sw.writeTimeout > 0 {
+		sw.conn.SetWriteDeadline(time.Now().Add(sw.writeTimeout))
+	}
+}
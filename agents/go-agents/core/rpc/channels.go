@@ -93,6 +93,10 @@ type Channel struct {
 	// to json and send to the client.
 	output chan interface{}
 
+	// Closed when the channel is closed, after that
+	// nothing is sent to the client.
+	closed chan struct{}
+
 	// If any value is send to this channel then
 	// physical connection associated with it along with
 	// output channel will be immediately closed.
@@ -183,6 +187,7 @@ func registerChannel(w http.ResponseWriter, r *http.Request, _ rest.Params) erro
 		RequestURI: r.RequestURI,
 		Events:     make(chan *Event),
 		output:     make(chan interface{}),
+		closed:     make(chan struct{}),
 		drop:       make(chan bool),
 		conn:       conn,
 	}
@@ -192,7 +197,7 @@ func registerChannel(w http.ResponseWriter, r *http.Request, _ rest.Params) erro
 
 	// send ping messages
 	go setupWSPinging(conn)
-	go transferAsJSON(conn, channel.output)
+	go transferAsJSON(conn, channel)
 	go redirectEventsToOutput(channel)
 	go handleMessages(readMessages(conn), channel)
 
@@ -229,11 +234,12 @@ func handleMessages(messageChan chan *WsMessage, channel Channel) {
 	}
 }
 
-// Closes all associated go channels(events, output, drop)
-// and physical websocket connection.
+// Closes all associated go channels(events, drop) and physical websocket connection.
+// The output is not closed as it may be written concurrently, its writers
+// and the reader stop as soon as closed is closed.
 func closeChannel(channel Channel) {
+	close(channel.closed)
 	close(channel.Events)
-	close(channel.output)
 	close(channel.drop)
 	if err := channel.conn.Close(); err != nil {
 		log.Println("Error closing connection, " + err.Error())
@@ -261,17 +267,39 @@ func readMessages(conn *websocket.Conn) chan *WsMessage {
 
 func redirectEventsToOutput(channel Channel) {
 	for event := range channel.Events {
-		channel.output <- event
+		if !channel.send(event) {
+			return
+		}
 	}
 }
 
-// transfers data from channel to physical connection,
-// tries to transform data to json.
-func transferAsJSON(conn *websocket.Conn, c chan interface{}) {
-	for message := range c {
-		err := conn.WriteJSON(message)
-		if err != nil {
-			log.Printf("Couldn't write message to the channel. Message: %T, %v", message, message)
+// Closed returns go channel which is closed when the channel is closed.
+func (channel Channel) Closed() <-chan struct{} {
+	return channel.closed
+}
+
+// Sends the message to the output, returns false
+// without sending the message if the channel is closed.
+func (channel Channel) send(message interface{}) bool {
+	select {
+	case channel.output <- message:
+		return true
+	case <-channel.closed:
+		return false
+	}
+}
+
+// transfers data from channel output to physical connection
+// until the channel is closed, tries to transform data to json.
+func transferAsJSON(conn *websocket.Conn, channel Channel) {
+	for {
+		select {
+		case message := <-channel.output:
+			if err := conn.WriteJSON(message); err != nil {
+				log.Printf("Couldn't write message to the channel. Message: %T, %v", message, message)
+			}
+		case <-channel.closed:
+			return
 		}
 	}
 }
@@ -23,19 +23,21 @@ type Transmitter struct {
 }
 
 // Send wraps the given message with 'rpc.Result' and sends it to the client.
+// The message is not sent if the channel is already closed.
 func (t *Transmitter) Send(message interface{}) {
-	t.Channel.output <- &Response{
+	t.Channel.send(&Response{
 		Version: "2.0",
 		ID:      t.id,
 		Result:  message,
-	}
+	})
 }
 
 // SendError wraps the given error with 'rpc.Result' and sends it to the client.
+// The error is not sent if the channel is already closed.
 func (t *Transmitter) SendError(err Error) {
-	t.Channel.output <- &Response{
+	t.Channel.send(&Response{
 		Version: "2.0",
 		ID:      t.id,
 		Error:   &err,
-	}
+	})
 }
@@ -12,16 +12,40 @@
 package exec
 
 import (
+	"encoding/base64"
 	"errors"
-	"github.com/eclipse/che/agents/go-agents/core/process"
-	"github.com/eclipse/che/agents/go-agents/core/rpc"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/eclipse/che/agents/go-agents/core/process"
+	"github.com/eclipse/che/agents/go-agents/core/rpc"
 )
 
 const (
 	// DefaultLogsPerPageLimit is default limit of logs per page on process output fetching
 	DefaultLogsPerPageLimit = 50
+
+	// DefaultWaitTimeout is how long process death is waited for if timeout is not specified
+	DefaultWaitTimeout = 30 * time.Second
+
+	// ServerReadTimeout is how long reading of the request by exec-agent server may take
+	ServerReadTimeout = 10 * time.Second
+
+	// ServerWriteTimeout is how long writing of the response by exec-agent server may take,
+	// streamed responses are not limited by it, instead each their write is limited
+	ServerWriteTimeout = 10 * time.Second
+)
+
+var (
+	// how many events may be queued for the http client, see eventsQueue
+	maxQueuedEvents = 4096
 )
 
 func maskFromTypes(types string) uint64 {
@@ -34,6 +58,8 @@ func maskFromTypes(types string) uint64 {
 			mask |= process.StdoutBit
 		case "process_status":
 			mask |= process.StatusBit
+		case "process_stats":
+			mask |= process.StatsBit
 		}
 	}
 	return mask
@@ -64,12 +90,299 @@ func checkCommand(command *process.Command) error {
 	if command.Name == "" {
 		return errors.New("Command name required")
 	}
+	if err := checkCommandLine(command); err != nil {
+		return err
+	}
+	if command.Timeout < 0 {
+		return errors.New("Command timeout must be >= 0")
+	}
+	if err := checkRestartPolicy(command); err != nil {
+		return err
+	}
+	if command.Readiness != nil {
+		if err := command.Readiness.Validate(); err != nil {
+			return err
+		}
+	}
+	if command.Limits != nil {
+		if err := command.Limits.Validate(); err != nil {
+			return err
+		}
+	}
+	if command.Output != nil {
+		if err := command.Output.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := process.CheckReadinessOutput(command.Readiness, command.Output); err != nil {
+		return err
+	}
+	if command.Tty {
+		if err := process.CheckTtySize(command.Cols, command.Rows); err != nil {
+			return err
+		}
+	}
+	if command.User != "" {
+		if err := process.CheckUser(command.User); err != nil {
+			return err
+		}
+	}
+	if err := checkEnv(command.Env); err != nil {
+		return err
+	}
+	if err := checkLabels(command.Labels); err != nil {
+		return err
+	}
+	return checkWorkingDir(command.WorkingDir)
+}
+
+// Checks whether either command line or argv is specified along with the allowed interpreter
+func checkCommandLine(command *process.Command) error {
+	if len(command.Argv) != 0 {
+		if command.CommandLine != "" {
+			return errors.New("Command line can't be used along with argv")
+		}
+		if command.Interpreter != "" {
+			return errors.New("Interpreter can't be used along with argv")
+		}
+		if command.Argv[0] == "" {
+			return errors.New("Program of argv must not be empty")
+		}
+		for _, arg := range command.Argv {
+			if strings.ContainsRune(arg, 0) {
+				return errors.New("Argv must not contain null characters")
+			}
+		}
+		return nil
+	}
 	if command.CommandLine == "" {
-		return errors.New("Command line required")
+		return errors.New("Command line or argv required")
+	}
+	return process.CheckInterpreter(command.Interpreter)
+}
+
+// Checks whether each step of the chain is valid
+func checkChain(steps []process.ChainStep) error {
+	if len(steps) == 0 {
+		return errors.New("Chain steps required")
+	}
+	for i := range steps {
+		if err := checkCommand(&steps[i].Command); err != nil {
+			return fmt.Errorf("Chain step %d is not valid. %s", i+1, err)
+		}
+		if err := process.CheckChainCondition(steps[i].Condition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Checks whether restart policy of the command is valid
+func checkRestartPolicy(command *process.Command) error {
+	switch command.RestartPolicy {
+	case "", process.RestartNever, process.RestartOnFailure, process.RestartAlways:
+	default:
+		return fmt.Errorf("Restart policy '%s' is not supported", command.RestartPolicy)
+	}
+	if command.MaxRetries < 0 {
+		return errors.New("Max retries must be >= 0")
+	}
+	if command.RestartBackoff < 0 {
+		return errors.New("Restart backoff must be >= 0")
+	}
+	return nil
+}
+
+// Checks whether environment variables names are valid
+func checkEnv(env map[string]string) error {
+	for k, v := range env {
+		if k == "" {
+			return errors.New("Environment variable name must not be empty")
+		}
+		if strings.ContainsAny(k, "=\x00") {
+			return fmt.Errorf("Environment variable name '%s' is not valid", k)
+		}
+		if strings.ContainsRune(v, 0) {
+			return fmt.Errorf("Value of environment variable '%s' is not valid", k)
+		}
+	}
+	return nil
+}
+
+// Checks whether labels keys are valid
+func checkLabels(labels map[string]string) error {
+	for k, v := range labels {
+		if strings.TrimSpace(k) != k || k == "" {
+			return fmt.Errorf("Label '%s' is not valid", k)
+		}
+		if strings.ContainsAny(k, "=!,") || strings.ContainsAny(v, ",") {
+			return fmt.Errorf("Label '%s' must not contain '=', '!' or ',' characters", k)
+		}
+	}
+	return nil
+}
+
+// Creates processes filter from the get processes call params,
+// if neither 'all' nor 'alive' specified then only alive processes are listed
+func newFilter(params GetProcessesParams) (process.Filter, error) {
+	filter := process.Filter{
+		Name:   params.Name,
+		Type:   params.Type,
+		Alive:  params.Alive,
+		SortBy: params.Sort,
+		Skip:   params.Skip,
+		Limit:  params.Limit,
+	}
+	if filter.Alive == nil && !params.All {
+		alive := true
+		filter.Alive = &alive
+	}
+	selector, err := process.ParseSelector(params.Labels)
+	if err != nil {
+		return filter, err
+	}
+	filter.Selector = selector
+	if filter.StartedAfter, err = process.ParseTime(params.StartedAfter, time.Time{}); err != nil {
+		return filter, errors.New("Bad format of 'startedAfter', " + err.Error())
+	}
+	if filter.StartedBefore, err = process.ParseTime(params.StartedBefore, time.Time{}); err != nil {
+		return filter, errors.New("Bad format of 'startedBefore', " + err.Error())
+	}
+	switch strings.ToLower(params.Order) {
+	case "", "asc":
+	case "desc":
+		filter.Desc = true
+	default:
+		return filter, fmt.Errorf("Order '%s' is not supported, use either 'asc' or 'desc'", params.Order)
+	}
+	return filter, filter.Validate()
+}
+
+// Creates logs search query from the search logs call params
+func newSearchQuery(params SearchLogsParams) (process.SearchQuery, error) {
+	query := process.SearchQuery{
+		Pids:    params.Pids,
+		Context: params.Context,
+		Skip:    params.Skip,
+		Limit:   params.Limit,
+	}
+	if params.Query == "" {
+		return query, errors.New("Search query required")
+	}
+	pattern, err := regexp.Compile(params.Query)
+	if err != nil {
+		return query, errors.New("Bad search query, " + err.Error())
+	}
+	query.Pattern = pattern
+	switch strings.ToLower(params.Kind) {
+	case "":
+	case "stdout":
+		kind := process.StdoutKind
+		query.Kind = &kind
+	case "stderr":
+		kind := process.StderrKind
+		query.Kind = &kind
+	default:
+		return query, fmt.Errorf("Kind '%s' is not supported, use either 'stdout' or 'stderr'", params.Kind)
+	}
+	if query.From, err = process.ParseTime(params.From, time.Time{}); err != nil {
+		return query, errors.New("Bad format of 'from', " + err.Error())
+	}
+	if query.Till, err = process.ParseTime(params.Till, time.Time{}); err != nil {
+		return query, errors.New("Bad format of 'till', " + err.Error())
+	}
+	return query, query.Validate()
+}
+
+// Checks whether working directory is an absolute path to an existing directory
+func checkWorkingDir(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	if !filepath.IsAbs(dir) {
+		return fmt.Errorf("Working directory '%s' must be an absolute path", dir)
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("Working directory '%s' is not accessible", dir)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("Working directory '%s' is not a directory", dir)
 	}
 	return nil
 }
 
+// Converts either text or base64 encoded data into the bytes
+// that should be written to the process input
+func inputBytes(text string, data string) ([]byte, error) {
+	if text != "" && data != "" {
+		return nil, errors.New("Only one of 'text' and 'data' may be specified")
+	}
+	if data != "" {
+		b, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return nil, errors.New("Bad format of 'data', base64 expected. " + err.Error())
+		}
+		return b, nil
+	}
+	return []byte(text), nil
+}
+
+// Writes input to the process and closes process input if required
+func writeInput(pid uint64, input []byte, closeInput bool) error {
+	if len(input) != 0 {
+		if err := process.WriteInput(pid, input); err != nil {
+			return err
+		}
+	}
+	if closeInput {
+		return process.CloseInput(pid)
+	}
+	return nil
+}
+
+// Kills the process, if graceful is true then the process is terminated
+// and killed only if it is still alive after the grace period in seconds,
+// non positive grace period means default one
+func kill(pid uint64, graceful bool, gracePeriodSec int) error {
+	if !graceful {
+		return process.Kill(pid)
+	}
+	gracePeriod := process.DefaultKillGracePeriod
+	if gracePeriodSec > 0 {
+		gracePeriod = time.Duration(gracePeriodSec) * time.Second
+	}
+	return process.KillGracefully(pid, gracePeriod)
+}
+
+// Sends the signal to the process group, if native pid is positive
+// then the signal is sent only to that native process of the process session
+func signal(pid uint64, nativePid int, sig syscall.Signal) error {
+	if nativePid > 0 {
+		return process.SignalNative(pid, nativePid, sig)
+	}
+	return process.Signal(pid, sig)
+}
+
+// Parses the timeout of waiting for the process death, the timeout is either
+// a duration like '30s', '5m' or a number of seconds, zero means no timeout
+func parseWaitTimeout(timeout string) (time.Duration, error) {
+	if timeout == "" {
+		return DefaultWaitTimeout, nil
+	}
+	if seconds, err := strconv.Atoi(timeout); err == nil {
+		if seconds < 0 {
+			return 0, errors.New("Wait timeout must be >= 0")
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	duration, err := time.ParseDuration(timeout)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("Wait timeout '%s' is not valid, use either duration e.g. '30s' or seconds", timeout)
+	}
+	return duration, nil
+}
+
 type rpcProcessEventConsumer struct {
 	rpcChannel chan *rpc.Event
 }
@@ -77,3 +390,43 @@ type rpcProcessEventConsumer struct {
 func (rpcConsumer *rpcProcessEventConsumer) Accept(e process.Event) {
 	rpcConsumer.rpcChannel <- rpc.NewEvent(e.Type(), e)
 }
+
+// Queues process events, so publishing of process events
+// is never blocked by the slow http client.
+// The queue holds up to maxQueuedEvents events, if the client doesn't keep up
+// the queue overflows and the following events are dropped, so the client
+// must be disconnected after the queued events are written.
+type eventsQueue struct {
+	mutex      sync.Mutex
+	events     []process.Event
+	overflowed bool
+	ready      chan bool
+}
+
+func newEventsQueue() *eventsQueue {
+	return &eventsQueue{ready: make(chan bool, 1)}
+}
+
+func (queue *eventsQueue) Accept(event process.Event) {
+	queue.mutex.Lock()
+	if len(queue.events) < maxQueuedEvents {
+		queue.events = append(queue.events, event)
+	} else {
+		queue.overflowed = true
+	}
+	queue.mutex.Unlock()
+	select {
+	case queue.ready <- true:
+	default:
+	}
+}
+
+// Returns all the queued events and clears the queue,
+// the returned flag is true if the events after the returned ones were dropped.
+func (queue *eventsQueue) take() ([]process.Event, bool) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	events := queue.events
+	queue.events = nil
+	return events, queue.overflowed
+}
@@ -12,14 +12,16 @@
 package exec
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
-	"math"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/eclipse/che/agents/go-agents/core/process"
@@ -28,6 +30,11 @@ import (
 	"github.com/eclipse/che/agents/go-agents/core/rpc"
 )
 
+var (
+	// used to generate unique ids of the logs followers subscribers
+	prevLogsFollowerID uint64
+)
+
 // HTTPRoutes provides all routes that should be handled by the process API
 var HTTPRoutes = rest.RoutesGroup{
 	Name: "Process Routes",
@@ -44,6 +51,20 @@ var HTTPRoutes = rest.RoutesGroup{
 			Path:       "/process/:pid",
 			HandleFunc: getProcessHF,
 		},
+		{
+			// the router doesn't allow '/process/chain' path along with '/process/:pid/...' paths,
+			// so the only supported value of the parameter is 'chain'
+			Method:     "POST",
+			Name:       "Start Chain",
+			Path:       "/process/:pid",
+			HandleFunc: startChainHF,
+		},
+		{
+			Method:     "GET",
+			Name:       "Get Chain",
+			Path:       "/chain/:id",
+			HandleFunc: getChainHF,
+		},
 		{
 			Method:     "DELETE",
 			Name:       "Kill Process",
@@ -56,12 +77,60 @@ var HTTPRoutes = rest.RoutesGroup{
 			Path:       "/process/:pid/logs",
 			HandleFunc: getProcessLogsHF,
 		},
+		{
+			Method:     "GET",
+			Name:       "Stream Process Events",
+			Path:       "/process/:pid/events",
+			HandleFunc: streamProcessEventsHF,
+		},
+		{
+			Method:     "GET",
+			Name:       "Wait Process",
+			Path:       "/process/:pid/wait",
+			HandleFunc: waitProcessHF,
+		},
+		{
+			Method:     "GET",
+			Name:       "Get Process Stats",
+			Path:       "/process/:pid/stats",
+			HandleFunc: getProcessStatsHF,
+		},
+		{
+			Method:     "GET",
+			Name:       "Get Process Tree",
+			Path:       "/process/:pid/tree",
+			HandleFunc: getProcessTreeHF,
+		},
+		{
+			Method:     "POST",
+			Name:       "Signal Process",
+			Path:       "/process/:pid/signal",
+			HandleFunc: signalProcessHF,
+		},
+		{
+			Method:     "POST",
+			Name:       "Forget Process",
+			Path:       "/process/:pid/forget",
+			HandleFunc: forgetProcessHF,
+		},
+		{
+			Method:     "POST",
+			Name:       "Write Process Input",
+			Path:       "/process/:pid/input",
+			HandleFunc: writeProcessInputHF,
+		},
 		{
 			Method:     "GET",
 			Name:       "Get Processes",
 			Path:       "/process",
 			HandleFunc: getProcessesHF,
 		},
+		{
+			Method:     "GET",
+			Name:       "Search Logs",
+			Path:       "/logs/search",
+			HandleFunc: searchLogsHF,
+		},
 	},
 }
 
@@ -96,6 +165,59 @@ func startProcessHF(w http.ResponseWriter, r *http.Request, p rest.Params) error
 	return restutil.WriteJSON(w, proc)
 }
 
+// StartChainParams represents the body of start chain request
+type StartChainParams struct {
+	Steps []process.ChainStep `json:"steps"`
+}
+
+func startChainHF(w http.ResponseWriter, r *http.Request, p rest.Params) error {
+	if p.Get("pid") != "chain" {
+		return rest.NotFound(fmt.Errorf("Path '%s' doesn't exist", r.URL.Path))
+	}
+	params := StartChainParams{}
+	if err := restutil.ReadJSON(r, &params); err != nil {
+		return err
+	}
+	if err := checkChain(params.Steps); err != nil {
+		return rest.BadRequest(err)
+	}
+
+	// If channel is provided then check whether it exists
+	// and subscribe it to the events of each step process
+	var subs []process.Subscriber
+	channelID := r.URL.Query().Get("channel")
+	if channelID != "" {
+		channel, ok := rpc.GetChannel(channelID)
+		if !ok {
+			m := fmt.Sprintf("Channel with id '%s' doesn't exist. Chain won't be started", channelID)
+			return rest.NotFound(errors.New(m))
+		}
+		subs = append(subs, process.Subscriber{
+			ID:       channelID,
+			Mask:     parseTypes(r.URL.Query().Get("types")),
+			Consumer: &rpcProcessEventConsumer{channel.Events},
+		})
+	}
+
+	chain, err := process.StartChain(params.Steps, subs...)
+	if err != nil {
+		return err
+	}
+	return restutil.WriteJSON(w, chain)
+}
+
+func getChainHF(w http.ResponseWriter, r *http.Request, p rest.Params) error {
+	id, err := strconv.ParseUint(p.Get("id"), 10, 64)
+	if err != nil || id == 0 {
+		return rest.BadRequest(errors.New("Chain id must be unsigned integer"))
+	}
+	chain, err := process.GetChain(id)
+	if err != nil {
+		return asHTTPError(err)
+	}
+	return restutil.WriteJSON(w, chain)
+}
+
 func getProcessHF(w http.ResponseWriter, r *http.Request, p rest.Params) error {
 	pid, err := parsePid(p.Get("pid"))
 	if err != nil {
@@ -114,7 +236,120 @@ func killProcessHF(w http.ResponseWriter, r *http.Request, p rest.Params) error
 	if err != nil {
 		return rest.BadRequest(err)
 	}
-	if err := process.Kill(pid); err != nil {
+	graceful, err := strconv.ParseBool(r.URL.Query().Get("graceful"))
+	if err != nil {
+		graceful = false
+	}
+	gracePeriod := restutil.IntQueryParam(r, "gracePeriod", 0)
+	if err := kill(pid, graceful, gracePeriod); err != nil {
+		return asHTTPError(err)
+	}
+	return nil
+}
+
+func forgetProcessHF(w http.ResponseWriter, r *http.Request, p rest.Params) error {
+	pid, err := parsePid(p.Get("pid"))
+	if err != nil {
+		return rest.BadRequest(err)
+	}
+	if err := process.Forget(pid); err != nil {
+		return asHTTPError(err)
+	}
+	return nil
+}
+
+func waitProcessHF(w http.ResponseWriter, r *http.Request, p rest.Params) error {
+	pid, err := parsePid(p.Get("pid"))
+	if err != nil {
+		return rest.BadRequest(err)
+	}
+	timeout, err := parseWaitTimeout(r.URL.Query().Get("timeout"))
+	if err != nil {
+		return rest.BadRequest(err)
+	}
+
+	// the wait may take longer than the server's write timeout, so the response is not limited by it
+	stream, err := rest.NewStreamWriter(w, r)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	proc, err := process.WaitOrCancel(pid, timeout, stream.Disconnected())
+	if err != nil {
+		rest.WriteError(stream, asHTTPError(err))
+	} else if err := restutil.WriteJSON(stream, proc); err != nil {
+		log.Printf("Error occurs on writing process %v into response. %s", pid, err)
+	}
+	return nil
+}
+
+func getProcessStatsHF(w http.ResponseWriter, r *http.Request, p rest.Params) error {
+	pid, err := parsePid(p.Get("pid"))
+	if err != nil {
+		return rest.BadRequest(err)
+	}
+	stats, err := process.GetStats(pid)
+	if err != nil {
+		return asHTTPError(err)
+	}
+	return restutil.WriteJSON(w, stats)
+}
+
+func getProcessTreeHF(w http.ResponseWriter, r *http.Request, p rest.Params) error {
+	pid, err := parsePid(p.Get("pid"))
+	if err != nil {
+		return rest.BadRequest(err)
+	}
+	tree, err := process.GetTree(pid)
+	if err != nil {
+		return asHTTPError(err)
+	}
+	return restutil.WriteJSON(w, tree)
+}
+
+func signalProcessHF(w http.ResponseWriter, r *http.Request, p rest.Params) error {
+	pid, err := parsePid(p.Get("pid"))
+	if err != nil {
+		return rest.BadRequest(err)
+	}
+	sig, err := process.ParseSignal(r.URL.Query().Get("name"))
+	if err != nil {
+		return rest.BadRequest(err)
+	}
+	nativePid := 0
+	if nativePidParam := r.URL.Query().Get("nativePid"); nativePidParam != "" {
+		nativePid, err = strconv.Atoi(nativePidParam)
+		if err != nil || nativePid <= 0 {
+			return rest.BadRequest(errors.New("NativePid must be a positive integer"))
+		}
+	}
+	if err := signal(pid, nativePid, sig); err != nil {
+		return asHTTPError(err)
+	}
+	return nil
+}
+
+type inputBody struct {
+	Text  string `json:"text"`
+	Data  string `json:"data"`
+	Close bool   `json:"close"`
+}
+
+func writeProcessInputHF(w http.ResponseWriter, r *http.Request, p rest.Params) error {
+	pid, err := parsePid(p.Get("pid"))
+	if err != nil {
+		return rest.BadRequest(err)
+	}
+	body := inputBody{}
+	if err := restutil.ReadJSON(r, &body); err != nil {
+		return rest.BadRequest(err)
+	}
+	input, err := inputBytes(body.Text, body.Data)
+	if err != nil {
+		return rest.BadRequest(err)
+	}
+	if err := writeInput(pid, input, body.Close); err != nil {
 		return asHTTPError(err)
 	}
 	return nil
@@ -127,6 +362,7 @@ type getLogsParams struct {
 	limit  int
 	skip   int
 	format string
+	follow bool
 }
 
 func getProcessLogsHF(w http.ResponseWriter, r *http.Request, p rest.Params) error {
@@ -135,28 +371,170 @@ func getProcessLogsHF(w http.ResponseWriter, r *http.Request, p rest.Params) err
 		return err
 	}
 
-	logs, err := process.ReadLogs(logsParams.pid, logsParams.from, logsParams.till)
+	// read only the latest logs, skipped logs are dropped from the end
+	logs, err := process.ReadLogsTail(logsParams.pid, logsParams.from, logsParams.till, logsParams.limit+logsParams.skip)
 	if err != nil {
 		return asHTTPError(err)
 	}
+	if logsParams.skip >= len(logs) {
+		logs = logs[:0]
+	} else {
+		logs = logs[:len(logs)-logsParams.skip]
+	}
 
-	len := len(logs)
-	fromIdx := int(math.Max(float64(len-logsParams.limit-logsParams.skip), 0))
-	toIdx := len - int(math.Min(float64(logsParams.skip), float64(len)))
+	if logsParams.follow {
+		return followLogs(w, r, logsParams, logs)
+	}
 
 	// Respond with an appropriate logs format, default json
-	switch strings.ToLower(logsParams.format) {
+	contentType, writeLog, ok := textLogsWriter(logsParams.format)
+	if !ok {
+		return restutil.WriteJSON(w, logs)
+	}
+	w.Header().Set("Content-Type", contentType)
+	for _, item := range logs {
+		if err := writeLog(w, item); err != nil {
+			log.Printf("Error occurs on writing logs of process %v into response. %s", logsParams.pid, err)
+		}
+	}
+	return nil
+}
+
+// Writes the latest logs and then keeps writing new logs until the process dies or client disconnects.
+// In json format each log message is written as a separate json object followed by a new line.
+func followLogs(w http.ResponseWriter, r *http.Request, params *getLogsParams, logs []*process.LogMessage) error {
+	// the logs which appeared after the latest written log are published to the subscriber
+	after := params.from.Add(-time.Nanosecond)
+	if len(logs) != 0 {
+		after = logs[len(logs)-1].Time
+	}
+	queue := newEventsQueue()
+	sub := process.Subscriber{
+		ID:       fmt.Sprintf("logs-%d", atomic.AddUint64(&prevLogsFollowerID, 1)),
+		Mask:     process.StdoutBit | process.StderrBit | process.StatusBit,
+		Consumer: queue,
+	}
+	if err := process.RestoreSubscriber(params.pid, sub, after); err != nil {
+		return asHTTPError(err)
+	}
+
+	// the logs are followed as long as the process runs, so it's not limited by the server's write timeout
+	stream, err := rest.NewStreamWriter(w, r)
+	if err != nil {
+		process.RemoveSubscriber(params.pid, sub.ID)
+		return err
+	}
+	defer stream.Close()
+
+	contentType, writeLog, ok := textLogsWriter(params.format)
+	if !ok {
+		contentType, writeLog = "application/json", writeJSONLog
+	}
+	stream.Header().Set("Content-Type", contentType)
+	stream.Header().Set("Cache-Control", "no-cache")
+	stream.WriteHeader(http.StatusOK)
+
+	for _, item := range logs {
+		if err := writeLog(stream, item); err != nil {
+			process.RemoveSubscriber(params.pid, sub.ID)
+			return nil
+		}
+	}
+	stream.Flush()
+
+	for {
+		select {
+		case <-queue.ready:
+			events, overflowed := queue.take()
+			for _, event := range events {
+				if event.Type() == process.DiedEventType {
+					return nil
+				}
+				output, ok := event.(*process.OutputEvent)
+				if !ok {
+					continue
+				}
+				kind := process.StdoutKind
+				if output.Type() == process.StderrEventType {
+					kind = process.StderrKind
+				}
+				item := &process.LogMessage{Kind: kind, Time: output.Time, Text: output.Text, Mode: output.Mode}
+				if err := writeLog(stream, item); err != nil {
+					process.RemoveSubscriber(params.pid, sub.ID)
+					return nil
+				}
+			}
+			stream.Flush()
+			// the client requests the logs after the last written one to continue
+			if overflowed {
+				process.RemoveSubscriber(params.pid, sub.ID)
+				return nil
+			}
+		case <-stream.Disconnected():
+			process.RemoveSubscriber(params.pid, sub.ID)
+			return nil
+		}
+	}
+}
+
+// Returns the content type and the writer of the logs in the text based format, one of
+// 'text', 'plain' or 'html'. False is returned if the format is not text based.
+func textLogsWriter(format string) (string, func(io.Writer, *process.LogMessage) error, bool) {
+	switch strings.ToLower(format) {
 	case "text":
-		for _, item := range logs[fromIdx:toIdx] {
-			line := fmt.Sprintf("[%s] %s \t %s\n", item.Kind, item.Time.Format(process.DateTimeFormat), item.Text)
-			if _, err := io.WriteString(w, line); err != nil {
-				log.Printf("Error occurs on writing logs of process %v into response. %s", logsParams.pid, err)
+		return "text/plain", writeTextLog, true
+	case "plain":
+		return "text/plain", writePlainLog, true
+	case "html":
+		// the style of the output is kept between the lines of the same kind
+		converters := make(map[process.LogKind]*process.AnsiHTMLConverter)
+		return "text/html", func(w io.Writer, item *process.LogMessage) error {
+			converter, ok := converters[item.Kind]
+			if !ok {
+				converter = process.NewAnsiHTMLConverter()
+				converters[item.Kind] = converter
 			}
+			return writeHTMLLog(w, item, converter)
+		}, true
+	}
+	return "", nil, false
+}
+
+// Writes the log in the text format without escape sequences.
+func writePlainLog(w io.Writer, item *process.LogMessage) error {
+	plain := *item
+	plain.Text = process.StripAnsi(decodedText(item))
+	return writeTextLog(w, &plain)
+}
+
+// Writes the log as the html element which class is the kind of the log.
+func writeHTMLLog(w io.Writer, item *process.LogMessage, converter *process.AnsiHTMLConverter) error {
+	line := fmt.Sprintf("<div class=\"%s\" data-time=\"%s\">%s</div>\n",
+		strings.ToLower(item.Kind.String()),
+		item.Time.Format(process.DateTimeFormat),
+		converter.Convert(decodedText(item)))
+	_, err := io.WriteString(w, line)
+	return err
+}
+
+// Returns the text of the log, the text of raw output mode is decoded from base64.
+func decodedText(item *process.LogMessage) string {
+	if item.Mode == process.RawOutputMode {
+		if decoded, err := base64.StdEncoding.DecodeString(item.Text); err == nil {
+			return string(decoded)
 		}
-	default:
-		return restutil.WriteJSON(w, logs[fromIdx:toIdx])
 	}
-	return nil
+	return item.Text
+}
+
+func writeTextLog(w io.Writer, item *process.LogMessage) error {
+	line := fmt.Sprintf("[%s] %s \t %s\n", item.Kind, item.Time.Format(process.DateTimeFormat), item.Text)
+	_, err := io.WriteString(w, line)
+	return err
+}
+
+func writeJSONLog(w io.Writer, item *process.LogMessage) error {
+	return json.NewEncoder(w).Encode(item)
 }
 
 func parseGetLogsParameters(r *http.Request, p rest.Params) (*getLogsParams, error) {
@@ -182,7 +560,16 @@ func parseGetLogsParameters(r *http.Request, p rest.Params) (*getLogsParams, err
 	// limit logs from the latest to the earliest
 	// limit - how many the latest logs will be present
 	// skip - how many log lines should be skipped from the end
+	// tail - the same as limit, overrides it if specified
 	limit := restutil.IntQueryParam(r, "limit", DefaultLogsPerPageLimit)
+	if tail := r.URL.Query().Get("tail"); tail != "" {
+		if limit, err = strconv.Atoi(tail); err != nil {
+			return nil, rest.BadRequest(errors.New("Bad format of 'tail', " + err.Error()))
+		}
+		if limit < 1 {
+			return nil, rest.BadRequest(errors.New("Required 'tail' to be > 0"))
+		}
+	}
 	skip := restutil.IntQueryParam(r, "skip", 0)
 	if limit < 1 {
 		return nil, rest.BadRequest(errors.New("Required 'limit' to be > 0"))
@@ -191,6 +578,20 @@ func parseGetLogsParameters(r *http.Request, p rest.Params) (*getLogsParams, err
 		return nil, rest.BadRequest(errors.New("Required 'skip' to be >= 0"))
 	}
 
+	// follow - whether to keep writing new logs until the process dies
+	follow := false
+	if followParam := r.URL.Query().Get("follow"); followParam != "" {
+		if follow, err = strconv.ParseBool(followParam); err != nil {
+			return nil, rest.BadRequest(errors.New("Bad format of 'follow', " + err.Error()))
+		}
+	}
+	if follow && r.URL.Query().Get("till") != "" {
+		return nil, rest.BadRequest(errors.New("Parameter 'till' can't be used to follow logs"))
+	}
+	if follow && skip > 0 {
+		return nil, rest.BadRequest(errors.New("Parameter 'skip' can't be used to follow logs"))
+	}
+
 	format := r.URL.Query().Get("format")
 
 	return &getLogsParams{
@@ -200,20 +601,85 @@ func parseGetLogsParameters(r *http.Request, p rest.Params) (*getLogsParams, err
 		limit:  limit,
 		skip:   skip,
 		format: format,
+		follow: follow,
 	}, nil
 }
 
 func getProcessesHF(w http.ResponseWriter, r *http.Request, _ rest.Params) error {
-	all, err := strconv.ParseBool(r.URL.Query().Get("all"))
+	query := r.URL.Query()
+	all, err := strconv.ParseBool(query.Get("all"))
 	if err != nil {
 		all = false
 	}
-	return restutil.WriteJSON(w, process.GetProcesses(all))
+	params := GetProcessesParams{
+		All:           all,
+		Labels:        query.Get("labels"),
+		Name:          query.Get("name"),
+		Type:          query.Get("type"),
+		StartedAfter:  query.Get("startedAfter"),
+		StartedBefore: query.Get("startedBefore"),
+		Sort:          query.Get("sort"),
+		Order:         query.Get("order"),
+		Skip:          restutil.IntQueryParam(r, "skip", 0),
+		Limit:         restutil.IntQueryParam(r, "limit", 0),
+	}
+	if aliveParam := query.Get("alive"); aliveParam != "" {
+		alive, err := strconv.ParseBool(aliveParam)
+		if err != nil {
+			return rest.BadRequest(errors.New("Alive must be either 'true' or 'false'"))
+		}
+		params.Alive = &alive
+	}
+	filter, err := newFilter(params)
+	if err != nil {
+		return rest.BadRequest(err)
+	}
+	return restutil.WriteJSON(w, process.GetProcesses(filter))
+}
+
+func searchLogsHF(w http.ResponseWriter, r *http.Request, _ rest.Params) error {
+	query := r.URL.Query()
+	params := SearchLogsParams{
+		Query:   query.Get("q"),
+		Kind:    query.Get("kind"),
+		From:    query.Get("from"),
+		Till:    query.Get("till"),
+		Context: restutil.IntQueryParam(r, "context", 0),
+		Skip:    restutil.IntQueryParam(r, "skip", 0),
+		Limit:   restutil.IntQueryParam(r, "limit", 0),
+	}
+
+	// pids may be either comma separated or repeated
+	for _, value := range query["pid"] {
+		for _, item := range strings.Split(value, ",") {
+			pid, err := parsePid(strings.TrimSpace(item))
+			if err != nil {
+				return rest.BadRequest(err)
+			}
+			params.Pids = append(params.Pids, pid)
+		}
+	}
+
+	searchQuery, err := newSearchQuery(params)
+	if err != nil {
+		return rest.BadRequest(err)
+	}
+	result, err := process.SearchLogs(searchQuery)
+	if err != nil {
+		return asHTTPError(err)
+	}
+	return restutil.WriteJSON(w, result)
 }
 
 func asHTTPError(err error) error {
 	if npErr, ok := err.(*process.NoProcessError); ok {
 		return rest.NotFound(npErr)
+	} else if nsErr, ok := err.(*process.NotInSessionError); ok {
+		return rest.BadRequest(nsErr)
+	} else if aErr, ok := err.(*process.AliveError); ok {
+		return rest.Conflict(aErr)
+	} else if ncErr, ok := err.(*process.NoChainError); ok {
+		return rest.NotFound(ncErr)
 	}
 	return err
 }
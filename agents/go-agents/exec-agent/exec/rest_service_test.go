@@ -3,7 +3,9 @@ package exec
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -57,6 +59,46 @@ func TestStartProcessFailsIfCommandIsInvalid(t *testing.T) {
 			CommandLine: "echo test",
 			Type:        "test",
 		},
+		{
+			Name:        "test",
+			CommandLine: "echo test",
+			WorkingDir:  "relative/dir",
+		},
+		{
+			Name:        "test",
+			CommandLine: "echo test",
+			WorkingDir:  "/" + strconv.FormatInt(time.Now().UnixNano(), 36),
+		},
+		{
+			Name:        "test",
+			CommandLine: "echo test",
+			Env:         map[string]string{"INVALID=NAME": "value"},
+		},
+		{
+			Name:        "test",
+			CommandLine: "echo test",
+			Readiness:   &process.ReadinessProbe{Pattern: "(unclosed"},
+		},
+		{
+			Name:        "test",
+			CommandLine: "echo test",
+			Limits:      &process.Limits{CPUShares: 100000},
+		},
+		{
+			Name:        "test",
+			CommandLine: "echo test",
+			User:        "nobody",
+		},
+		{
+			Name:        "test",
+			CommandLine: "print('test')",
+			Interpreter: "python",
+		},
+		{
+			Name:        "test",
+			CommandLine: "echo test",
+			Argv:        []string{"echo", "test"},
+		},
 	}
 
 	for _, command := range invalidCommands {
@@ -72,6 +114,69 @@ func TestStartProcessFailsIfCommandIsInvalid(t *testing.T) {
 	}
 }
 
+func TestStartsChainAndGetsItsStatus(t *testing.T) {
+	router := rest.NewDefaultRouter("", []rest.RoutesGroup{HTTPRoutes})
+	body := StartChainParams{
+		Steps: []process.ChainStep{
+			{Command: process.Command{Name: "build", CommandLine: "echo build"}},
+			{Command: process.Command{Name: "run", CommandLine: "echo run"}, Condition: process.RunAlways},
+		},
+	}
+	req, err := http.NewRequest("POST", "/process/chain", asJSONReader(t, body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+
+	failIfDifferent(t, http.StatusOK, rr.Code, "status code")
+	chain := process.Chain{}
+	json.Unmarshal(rr.Body.Bytes(), &chain)
+	failIfDifferent(t, 2, len(chain.Steps), "chain steps")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for chain.Status == process.RunningStatus && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		req, err = http.NewRequest("GET", "/chain/"+strconv.FormatUint(chain.ID, 10), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		failIfDifferent(t, http.StatusOK, rr.Code, "status code")
+		json.Unmarshal(rr.Body.Bytes(), &chain)
+	}
+	failIfDifferent(t, process.SucceededStatus, chain.Status, "chain status")
+}
+
+func TestStartChainFailsIfStepsAreInvalid(t *testing.T) {
+	invalidBodies := []StartChainParams{
+		{},
+		{Steps: []process.ChainStep{{Command: process.Command{Name: "build"}}}},
+		{Steps: []process.ChainStep{{Command: process.Command{Name: "build", CommandLine: "echo"}, Condition: "never"}}},
+	}
+	for _, body := range invalidBodies {
+		req, err := http.NewRequest("POST", "/process/chain", asJSONReader(t, body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+
+		asHTTPHandlerFunc(startChainHF, "pid", "chain").ServeHTTP(rr, req)
+
+		failIfDifferent(t, http.StatusBadRequest, rr.Code, "status code")
+	}
+
+	req, err := http.NewRequest("POST", "/process/1", asJSONReader(t, StartChainParams{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	asHTTPHandlerFunc(startChainHF, "pid", "1").ServeHTTP(rr, req)
+	failIfDifferent(t, http.StatusNotFound, rr.Code, "status code")
+}
+
 func TestGetsExistingProcess(t *testing.T) {
 	exp := startAndWaitProcess(t, "echo hello")
 
@@ -169,6 +274,10 @@ func TestGetsProcessLogs(t *testing.T) {
 			expectedLogs: realLogs[9:],
 			queryString:  "limit=1",
 		},
+		{
+			expectedLogs: realLogs[7:],
+			queryString:  "tail=3&limit=1",
+		},
 		{
 			expectedLogs: realLogs[6:],
 			queryString:  query("from", realLogs[6].Time.Format(process.DateTimeFormat)),
@@ -207,6 +316,526 @@ func TestGetsProcessLogs(t *testing.T) {
 	}
 }
 
+func TestFollowsProcessLogsUntilProcessDies(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "exec-agent-follow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	process.SetLogsDir(dir)
+	defer process.WipeLogs()
+
+	mp, err := process.NewBuilder().CmdName("test").CmdLine("echo 1 && echo 2 && sleep 0.3 && echo 3").Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	strPid := strconv.Itoa(int(mp.Pid))
+	req, err := http.NewRequest("GET", "/process/"+strPid+"/logs?follow=true&tail=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	asHTTPHandlerFunc(getProcessLogsHF, "pid", strPid).ServeHTTP(rr, req)
+
+	failIfDifferent(t, http.StatusOK, rr.Code, "status code")
+	logs := []process.LogMessage{}
+	decoder := json.NewDecoder(rr.Body)
+	for decoder.More() {
+		message := process.LogMessage{}
+		if err := decoder.Decode(&message); err != nil {
+			t.Fatal(err)
+		}
+		logs = append(logs, message)
+	}
+	failIfDifferent(t, 2, len(logs), "logs len")
+	failIfDifferent(t, "2", logs[0].Text, "first log text")
+	failIfDifferent(t, "3", logs[1].Text, "second log text")
+}
+
+func TestFollowsProcessLogsInTextFormat(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "exec-agent-follow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	process.SetLogsDir(dir)
+	defer process.WipeLogs()
+
+	mp := startAndWaitProcess(t, "printf \"1\n2\n3\"")
+
+	strPid := strconv.Itoa(int(mp.Pid))
+	req, err := http.NewRequest("GET", "/process/"+strPid+"/logs?follow=true&format=text", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	asHTTPHandlerFunc(getProcessLogsHF, "pid", strPid).ServeHTTP(rr, req)
+
+	failIfDifferent(t, http.StatusOK, rr.Code, "status code")
+	lines := strings.Split(strings.TrimSuffix(rr.Body.String(), "\n"), "\n")
+	failIfDifferent(t, 3, len(lines), "lines count")
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "[STDOUT] ") || !strings.HasSuffix(line, " \t "+strconv.Itoa(i+1)) {
+			t.Fatalf("Unexpected log line '%s'", line)
+		}
+	}
+}
+
+func TestGetsProcessLogsInPlainAndHTMLFormats(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "exec-agent-format")
+	if err != nil {
+		t.Fatal(err)
+	}
+	process.SetLogsDir(dir)
+	defer process.WipeLogs()
+
+	mp := startAndWaitProcess(t, "printf '\\033[32mok\\033[0m <done>'")
+	strPid := strconv.Itoa(int(mp.Pid))
+
+	cases := map[string]string{
+		"plain": " \t ok <done>\n",
+		"html":  `"><span style="color:#00cd00">ok</span> &lt;done&gt;</div>` + "\n",
+	}
+	for format, suffix := range cases {
+		req, err := http.NewRequest("GET", "/process/"+strPid+"/logs?format="+format, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+
+		asHTTPHandlerFunc(getProcessLogsHF, "pid", strPid).ServeHTTP(rr, req)
+
+		failIfDifferent(t, http.StatusOK, rr.Code, "status code")
+		if body := rr.Body.String(); !strings.HasSuffix(body, suffix) {
+			t.Fatalf("Expected logs in '%s' format to end with %q but got %q", format, suffix, body)
+		}
+	}
+}
+
+func TestGetsRawProcessLogsDecodedInHTMLFormat(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "exec-agent-format")
+	if err != nil {
+		t.Fatal(err)
+	}
+	process.SetLogsDir(dir)
+	defer process.WipeLogs()
+
+	captor := processtest.NewEventsCaptor(process.DiedEventType)
+	captor.Capture()
+	mp, err := process.NewBuilder().
+		CmdLine("printf '\\033[31merr' >&2 && sleep 0.1 && printf 'out' && sleep 0.1 && printf ' <still red>' >&2").
+		CmdOutput(&process.OutputOptions{Mode: process.RawOutputMode}).
+		SubscribeDefault("test", captor).
+		Start()
+	if err != nil {
+		captor.Stop()
+		t.Fatal(err)
+	}
+	if ok := <-captor.Wait(2 * time.Second); !ok {
+		t.Fatalf("Process %d doesn't finish its execution in 2 seconds", mp.Pid)
+	}
+	strPid := strconv.Itoa(int(mp.Pid))
+
+	req, err := http.NewRequest("GET", "/process/"+strPid+"/logs?format=html", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	asHTTPHandlerFunc(getProcessLogsHF, "pid", strPid).ServeHTTP(rr, req)
+
+	failIfDifferent(t, http.StatusOK, rr.Code, "status code")
+	body := rr.Body.String()
+	for _, expected := range []string{
+		`"><span style="color:#cd0000">err</span></div>`,
+		`">out</div>`,
+		`"><span style="color:#cd0000"> &lt;still red&gt;</span></div>`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Fatalf("Expected logs to contain %q but got %q", expected, body)
+		}
+	}
+}
+
+func TestGetProcessLogsFailsIfParamsAreInvalid(t *testing.T) {
+	for _, queryString := range []string{"tail=0", "tail=x", "follow=x", "follow=true&skip=1", "follow=true&till=2017-01-01T00:00:00Z"} {
+		req, err := http.NewRequest("GET", "/process/1/logs?"+queryString, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+
+		asHTTPHandlerFunc(getProcessLogsHF, "pid", "1").ServeHTTP(rr, req)
+
+		failIfDifferent(t, http.StatusBadRequest, rr.Code, "status code of query "+queryString)
+	}
+}
+
+func TestForgetsDeadProcess(t *testing.T) {
+	mp := startAndWaitProcess(t, "echo hello")
+	strPid := strconv.Itoa(int(mp.Pid))
+
+	for _, expectedCode := range []int{http.StatusOK, http.StatusNotFound} {
+		req, err := http.NewRequest("POST", "/process/"+strPid+"/forget", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		asHTTPHandlerFunc(forgetProcessHF, "pid", strPid).ServeHTTP(rr, req)
+		failIfDifferent(t, expectedCode, rr.Code, "status code")
+	}
+}
+
+func TestForgetProcessFailsIfProcessIsAlive(t *testing.T) {
+	mp, err := process.NewBuilder().CmdName("test").CmdLine("sleep 10").Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the process must be dead before the next tests, as some of them expect no alive processes
+	defer func() {
+		process.Kill(mp.Pid)
+		process.Wait(mp.Pid, 2*time.Second)
+	}()
+	strPid := strconv.Itoa(int(mp.Pid))
+
+	req, err := http.NewRequest("POST", "/process/"+strPid+"/forget", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	asHTTPHandlerFunc(forgetProcessHF, "pid", strPid).ServeHTTP(rr, req)
+	failIfDifferent(t, http.StatusConflict, rr.Code, "status code")
+}
+
+func TestSearchesLogs(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "exec-agent-search")
+	if err != nil {
+		t.Fatal(err)
+	}
+	process.SetLogsDir(dir)
+	defer process.WipeLogs()
+
+	mp := startAndWaitProcess(t, "printf \"compiling\nBUILD FAILURE\n\"")
+	strPid := strconv.Itoa(int(mp.Pid))
+
+	req, err := http.NewRequest("GET", "/logs/search?"+query("q", "BUILD F.*", "pid", strPid, "context", "1"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	asHTTPHandlerFunc(searchLogsHF).ServeHTTP(rr, req)
+
+	failIfDifferent(t, http.StatusOK, rr.Code, "status code")
+	result := process.SearchResult{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	failIfDifferent(t, 1, len(result.Matches), "matches count")
+	failIfDifferent(t, mp.Pid, result.Matches[0].Pid, "match pid")
+	failIfDifferent(t, 2, result.Matches[0].Line, "match line")
+	failIfDifferent(t, "compiling", result.Matches[0].Before[0].Text, "context log")
+}
+
+func TestSearchLogsFailsIfParamsAreInvalid(t *testing.T) {
+	for _, queryString := range []string{"", "q=(", "q=x&kind=stdin", "q=x&pid=abc", "q=x&context=100", "q=x&from=yesterday"} {
+		req, err := http.NewRequest("GET", "/logs/search?"+queryString, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		asHTTPHandlerFunc(searchLogsHF).ServeHTTP(rr, req)
+		failIfDifferent(t, http.StatusBadRequest, rr.Code, "status code of query "+queryString)
+	}
+}
+
+func TestWritesProcessInput(t *testing.T) {
+	captor := processtest.NewEventsCaptor(process.DiedEventType)
+	captor.Capture()
+	mp, err := process.NewBuilder().CmdLine("read line && echo $line").CmdStdin().SubscribeDefault("test", captor).Start()
+	if err != nil {
+		captor.Stop()
+		t.Fatal(err)
+	}
+
+	// data is base64 encoded 'hello\n'
+	strPid := strconv.Itoa(int(mp.Pid))
+	body := strings.NewReader(`{ "data" : "aGVsbG8K", "close" : true }`)
+	req, err := http.NewRequest("POST", "/process/"+strPid+"/input", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	asHTTPHandlerFunc(writeProcessInputHF, "pid", strPid).ServeHTTP(rr, req)
+	failIfDifferent(t, http.StatusOK, rr.Code, "status code")
+
+	if ok := <-captor.Wait(2 * time.Second); !ok {
+		process.Kill(mp.Pid)
+		t.Fatal("Process doesn't finish its execution in 2 seconds")
+	}
+	out := captor.Events()[1].(*process.OutputEvent)
+	failIfDifferent(t, "hello", out.Text, "output")
+}
+
+func TestSignalProcessFailsIfSignalIsInvalid(t *testing.T) {
+	req, err := http.NewRequest("POST", "/process/1/signal?name=SEGV", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	asHTTPHandlerFunc(signalProcessHF, "pid", "1").ServeHTTP(rr, req)
+
+	failIfDifferent(t, http.StatusBadRequest, rr.Code, "status code")
+}
+
+func TestSignalProcessFailsIfNativePidIsInvalid(t *testing.T) {
+	req, err := http.NewRequest("POST", "/process/1/signal?name=TERM&nativePid=abc", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	asHTTPHandlerFunc(signalProcessHF, "pid", "1").ServeHTTP(rr, req)
+
+	failIfDifferent(t, http.StatusBadRequest, rr.Code, "status code")
+}
+
+func TestGetProcessesFailsIfFilterIsInvalid(t *testing.T) {
+	for _, q := range []string{
+		query("sort", "nativePid"),
+		query("order", "random"),
+		query("alive", "maybe"),
+		query("labels", "=value"),
+		query("startedAfter", "yesterday"),
+	} {
+		req, err := http.NewRequest("GET", "/process?"+q, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+
+		asHTTPHandlerFunc(getProcessesHF).ServeHTTP(rr, req)
+
+		failIfDifferent(t, http.StatusBadRequest, rr.Code, "status code of "+q)
+	}
+}
+
+func TestWaitsProcess(t *testing.T) {
+	exp := startAndWaitProcess(t, "exit 2")
+
+	strPid := strconv.Itoa(int(exp.Pid))
+	req, err := http.NewRequest("GET", "/process/"+strPid+"/wait?timeout=5s", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	asHTTPHandlerFunc(waitProcessHF, "pid", strPid).ServeHTTP(rr, req)
+
+	failIfDifferent(t, http.StatusOK, rr.Code, "status code")
+	res := &process.MachineProcess{}
+	json.Unmarshal(rr.Body.Bytes(), res)
+	failIfDifferent(t, false, res.Alive, "alive")
+	failIfDifferent(t, 2, res.ExitCode, "exit code")
+}
+
+func TestWaitsProcessLongerThanServerWriteTimeout(t *testing.T) {
+	// runs in parallel with other tests which outlive the server's write timeout
+	t.Parallel()
+	server := newProductionServer()
+	defer server.Close()
+
+	mp, err := process.NewBuilder().CmdName("test").CmdLine(outlivingWriteTimeoutCmd("exit 3")).Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the default wait timeout is used
+	resp, err := outlivingWriteTimeoutClient().Get(server.URL + "/process/" + strconv.Itoa(int(mp.Pid)) + "/wait")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	failIfDifferent(t, http.StatusOK, resp.StatusCode, "status code")
+	res := &process.MachineProcess{}
+	if err := json.NewDecoder(resp.Body).Decode(res); err != nil {
+		t.Fatal(err)
+	}
+	failIfDifferent(t, false, res.Alive, "alive")
+	failIfDifferent(t, 3, res.ExitCode, "exit code")
+}
+
+func TestWaitProcessRespondsNotFoundIfNoProcess(t *testing.T) {
+	server := newProductionServer()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/process/" + strconv.Itoa(math.MaxInt32) + "/wait")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	failIfDifferent(t, http.StatusNotFound, resp.StatusCode, "status code")
+}
+
+func TestWaitProcessFailsIfTimeoutIsInvalid(t *testing.T) {
+	for _, timeout := range []string{"-1", "forever", "-5s"} {
+		req, err := http.NewRequest("GET", "/process/1/wait?"+query("timeout", timeout), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+
+		asHTTPHandlerFunc(waitProcessHF, "pid", "1").ServeHTTP(rr, req)
+
+		failIfDifferent(t, http.StatusBadRequest, rr.Code, "status code of timeout "+timeout)
+	}
+}
+
+func TestStreamsProcessEventsUntilProcessDies(t *testing.T) {
+	mp, err := process.NewBuilder().CmdName("test").CmdLine("sleep 0.3 && echo hello").Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	strPid := strconv.Itoa(int(mp.Pid))
+	req, err := http.NewRequest("GET", "/process/"+strPid+"/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	asHTTPHandlerFunc(streamProcessEventsHF, "pid", strPid).ServeHTTP(rr, req)
+
+	failIfDifferent(t, http.StatusOK, rr.Code, "status code")
+	failIfDifferent(t, "text/event-stream", rr.Header().Get("Content-Type"), "content type")
+	body := rr.Body.String()
+	if !strings.Contains(body, "event: process_stdout\ndata: ") || !strings.Contains(body, `"text":"hello"`) {
+		t.Fatalf("Expected stream to contain stdout event, but it is '%s'", body)
+	}
+	if !strings.HasSuffix(body, "}\n\n") || !strings.Contains(body, "event: process_died\n") {
+		t.Fatalf("Expected stream to end with died event, but it is '%s'", body)
+	}
+}
+
+func TestResumesProcessEventsStreamFromLastEventID(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "exec-agent-sse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	process.SetLogsDir(dir)
+	defer process.WipeLogs()
+
+	mp := startAndWaitProcess(t, "printf \"1\n2\n3\"")
+	logs, err := process.ReadAllLogs(mp.Pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	strPid := strconv.Itoa(int(mp.Pid))
+	req, err := http.NewRequest("GET", "/process/"+strPid+"/events?types=stdout", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Last-Event-ID", logs[0].Time.Format(process.DateTimeFormat))
+	rr := httptest.NewRecorder()
+
+	asHTTPHandlerFunc(streamProcessEventsHF, "pid", strPid).ServeHTTP(rr, req)
+
+	failIfDifferent(t, http.StatusOK, rr.Code, "status code")
+	body := rr.Body.String()
+	failIfDifferent(t, 2, strings.Count(body, "event: process_stdout"), "stdout events count")
+	if strings.Contains(body, `"text":"1"`) || strings.Contains(body, "process_died") {
+		t.Fatalf("Expected stream to contain only stdout after the last event, but it is '%s'", body)
+	}
+}
+
+func TestEndsProcessEventsStreamIfClientDoesNotKeepUp(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "exec-agent-sse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	process.SetLogsDir(dir)
+	defer process.WipeLogs()
+	defer func(prev int) { maxQueuedEvents = prev }(maxQueuedEvents)
+	maxQueuedEvents = 1
+
+	mp := startAndWaitProcess(t, "printf \"1\n2\n3\"")
+
+	strPid := strconv.Itoa(int(mp.Pid))
+	req, err := http.NewRequest("GET", "/process/"+strPid+"/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	asHTTPHandlerFunc(streamProcessEventsHF, "pid", strPid).ServeHTTP(rr, req)
+
+	failIfDifferent(t, http.StatusOK, rr.Code, "status code")
+	body := rr.Body.String()
+	if strings.Count(body, "event: ") != 1 || strings.Contains(body, "process_died") {
+		t.Fatalf("Expected stream to end after the queued event, but it is '%s'", body)
+	}
+}
+
+func TestStreamsProcessEventsLongerThanServerWriteTimeout(t *testing.T) {
+	// runs in parallel with other tests which outlive the server's write timeout
+	t.Parallel()
+	server := newProductionServer()
+	defer server.Close()
+
+	mp, err := process.NewBuilder().CmdName("test").CmdLine(outlivingWriteTimeoutCmd("echo done")).Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := outlivingWriteTimeoutClient().Get(server.URL + "/process/" + strconv.Itoa(int(mp.Pid)) + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failIfDifferent(t, http.StatusOK, resp.StatusCode, "status code")
+	failIfDifferent(t, "text/event-stream", resp.Header.Get("Content-Type"), "content type")
+	if !strings.Contains(string(body), `"text":"done"`) || !strings.Contains(string(body), "event: process_died\n") {
+		t.Fatalf("Expected stream to contain the output and died event, but it is '%s'", body)
+	}
+}
+
+func TestFollowsProcessLogsLongerThanServerWriteTimeout(t *testing.T) {
+	// runs in parallel with other tests which outlive the server's write timeout
+	t.Parallel()
+	server := newProductionServer()
+	defer server.Close()
+
+	mp, err := process.NewBuilder().CmdName("test").CmdLine("echo started && " + outlivingWriteTimeoutCmd("echo done")).Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := outlivingWriteTimeoutClient().Get(server.URL + "/process/" + strconv.Itoa(int(mp.Pid)) + "/logs?follow=true&format=text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failIfDifferent(t, http.StatusOK, resp.StatusCode, "status code")
+	if !strings.Contains(string(body), "started\n") || !strings.Contains(string(body), "done\n") {
+		t.Fatalf("Expected followed logs to contain the whole output, but they are '%s'", body)
+	}
+}
+
 func query(kv ...string) string {
 	if len(kv) == 0 {
 		return ""
@@ -251,6 +880,24 @@ func startAndWaitProcess(t *testing.T, cmd string) process.MachineProcess {
 	return mp
 }
 
+// Starts the server with exec-agent routes and the timeouts exec-agent server is configured with.
+func newProductionServer() *httptest.Server {
+	server := httptest.NewUnstartedServer(rest.NewDefaultRouter("", []rest.RoutesGroup{HTTPRoutes}))
+	server.Config.ReadTimeout = ServerReadTimeout
+	server.Config.WriteTimeout = ServerWriteTimeout
+	server.Start()
+	return server
+}
+
+// Returns the command which runs the given command after the server's write timeout expires.
+func outlivingWriteTimeoutCmd(cmd string) string {
+	return fmt.Sprintf("sleep %d && %s", int((ServerWriteTimeout+time.Second)/time.Second), cmd)
+}
+
+func outlivingWriteTimeoutClient() *http.Client {
+	return &http.Client{Timeout: 2 * (ServerWriteTimeout + time.Second)}
+}
+
 func failIfDifferent(t *testing.T, expected interface{}, actual interface{}, context string) {
 	if expected != actual {
 		t.Fatalf("Expected to receive '%v' %s but received '%v'", expected, context, actual)
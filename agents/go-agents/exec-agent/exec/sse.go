@@ -0,0 +1,157 @@
+//
+// Copyright (c) 2012-2017 Codenvy, S.A.
+// All rights reserved. This program and the accompanying materials
+// are made available under the terms of the Eclipse Public License v1.0
+// which accompanies this distribution, and is available at
+// http://www.eclipse.org/legal/epl-v10.html
+//
+// Contributors:
+//   Codenvy, S.A. - initial API and implementation
+//
+
+package exec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/eclipse/che/agents/go-agents/core/process"
+	"github.com/eclipse/che/agents/go-agents/core/rest"
+)
+
+var (
+	// used to generate unique ids of the server-sent events subscribers
+	prevSSEID uint64
+)
+
+// Streams process events as server-sent events until the process dies or client disconnects.
+// The id of each event is its time, so the client which reconnects with 'Last-Event-ID'
+// receives the output which appeared after that time from the process logs.
+func streamProcessEventsHF(w http.ResponseWriter, r *http.Request, p rest.Params) error {
+	pid, err := parsePid(p.Get("pid"))
+	if err != nil {
+		return rest.BadRequest(err)
+	}
+	mask := parseTypes(r.URL.Query().Get("types"))
+	lastEventID := r.Header.Get("Last-Event-ID")
+	after, err := process.ParseTime(lastEventID, time.Time{})
+	if err != nil {
+		return rest.BadRequest(errors.New("Bad format of 'Last-Event-ID', " + err.Error()))
+	}
+
+	// process status events are always received as died event ends the stream
+	consumer := newEventsQueue()
+	sub := process.Subscriber{
+		ID:       fmt.Sprintf("sse-%d", atomic.AddUint64(&prevSSEID, 1)),
+		Mask:     mask | process.StatusBit,
+		Consumer: consumer,
+	}
+	if err := subscribeSSE(pid, sub, lastEventID != "", after); err != nil {
+		return asHTTPError(err)
+	}
+
+	// the stream lasts as long as the process runs, so it's not limited by the server's write timeout
+	stream, err := rest.NewStreamWriter(w, r)
+	if err != nil {
+		process.RemoveSubscriber(pid, sub.ID)
+		return err
+	}
+	defer stream.Close()
+
+	stream.Header().Set("Content-Type", "text/event-stream")
+	stream.Header().Set("Cache-Control", "no-cache")
+	stream.WriteHeader(http.StatusOK)
+	stream.Flush()
+
+	for {
+		select {
+		case <-consumer.ready:
+			events, overflowed := consumer.take()
+			for _, event := range events {
+				if eventBit(event)&mask != 0 {
+					if err := writeSSE(stream, event); err != nil {
+						process.RemoveSubscriber(pid, sub.ID)
+						return nil
+					}
+				}
+				if event.Type() == process.DiedEventType {
+					return nil
+				}
+			}
+			stream.Flush()
+			// the client resumes the stream from the last written event with 'Last-Event-ID'
+			if overflowed {
+				process.RemoveSubscriber(pid, sub.ID)
+				return nil
+			}
+		case <-stream.Disconnected():
+			process.RemoveSubscriber(pid, sub.ID)
+			return nil
+		}
+	}
+}
+
+// Subscribes to the process events, the logs are restored if the client resumes the stream
+// or if the process is dead, so the stream of dead process contains all its output.
+func subscribeSSE(pid uint64, sub process.Subscriber, resume bool, after time.Time) error {
+	p, err := process.Get(pid)
+	if err != nil {
+		return err
+	}
+	if !resume && p.Alive {
+		if err := process.AddSubscriber(pid, sub); err == nil {
+			return nil
+		}
+		// the process died after it was fetched
+	}
+	return process.RestoreSubscriber(pid, sub, after)
+}
+
+func writeSSE(w http.ResponseWriter, event process.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n",
+		eventTime(event).Format(process.DateTimeFormat),
+		event.Type(),
+		data)
+	return err
+}
+
+// Returns the type bit of the event, see process.DefaultMask.
+func eventBit(event process.Event) uint64 {
+	switch event.Type() {
+	case process.StdoutEventType:
+		return process.StdoutBit
+	case process.StderrEventType:
+		return process.StderrBit
+	case process.StatsEventType:
+		return process.StatsBit
+	default:
+		return process.StatusBit
+	}
+}
+
+func eventTime(event process.Event) time.Time {
+	switch e := event.(type) {
+	case *process.StartedEvent:
+		return e.Time
+	case *process.DiedEvent:
+		return e.Time
+	case *process.RestartingEvent:
+		return e.Time
+	case *process.ReadinessEvent:
+		return e.Time
+	case *process.StatsEvent:
+		return e.Time
+	case *process.OutputEvent:
+		return e.Time
+	default:
+		return time.Now()
+	}
+}
@@ -14,6 +14,7 @@ package exec
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"math"
 	"time"
 
@@ -31,12 +32,24 @@ const (
 	GetLogsMethod          = "process.getLogs"
 	GetProcessMethod       = "process.getProcess"
 	GetProcessesMethod     = "process.getProcesses"
+	InputMethod            = "process.input"
+	SignalMethod           = "process.signal"
+	GetStatsMethod         = "process.getStats"
+	GetTreeMethod          = "process.getTree"
+	WaitMethod             = "process.wait"
+	ForgetMethod           = "process.forget"
+	SearchLogsMethod       = "process.searchLogs"
+	ResizeMethod           = "process.resize"
+	StartChainMethod       = "process.startChain"
+	GetChainMethod         = "process.getChain"
 )
 
 // Error codes
 const (
 	NoSuchProcessErrorCode   = -32000
 	ProcessNotAliveErrorCode = -32001
+	ProcessAliveErrorCode    = -32002
+	NoSuchChainErrorCode     = -32003
 )
 
 // RPCRoutes provides all routes that should be handled by the process API
@@ -115,6 +128,96 @@ var RPCRoutes = rpc.RoutesGroup{
 			},
 			HandlerFunc: getProcessesReqHF,
 		},
+		{
+			Method: InputMethod,
+			DecoderFunc: func(body []byte) (interface{}, error) {
+				b := InputParams{}
+				err := json.Unmarshal(body, &b)
+				return b, err
+			},
+			HandlerFunc: inputReqHF,
+		},
+		{
+			Method: SignalMethod,
+			DecoderFunc: func(body []byte) (interface{}, error) {
+				b := SignalParams{}
+				err := json.Unmarshal(body, &b)
+				return b, err
+			},
+			HandlerFunc: signalReqHF,
+		},
+		{
+			Method: GetStatsMethod,
+			DecoderFunc: func(body []byte) (interface{}, error) {
+				b := GetStatsParams{}
+				err := json.Unmarshal(body, &b)
+				return b, err
+			},
+			HandlerFunc: getStatsReqHF,
+		},
+		{
+			Method: GetTreeMethod,
+			DecoderFunc: func(body []byte) (interface{}, error) {
+				b := GetTreeParams{}
+				err := json.Unmarshal(body, &b)
+				return b, err
+			},
+			HandlerFunc: getTreeReqHF,
+		},
+		{
+			Method: WaitMethod,
+			DecoderFunc: func(body []byte) (interface{}, error) {
+				b := WaitParams{}
+				err := json.Unmarshal(body, &b)
+				return b, err
+			},
+			HandlerFunc: waitReqHF,
+		},
+		{
+			Method: ForgetMethod,
+			DecoderFunc: func(body []byte) (interface{}, error) {
+				b := ForgetParams{}
+				err := json.Unmarshal(body, &b)
+				return b, err
+			},
+			HandlerFunc: forgetReqHF,
+		},
+		{
+			Method: SearchLogsMethod,
+			DecoderFunc: func(body []byte) (interface{}, error) {
+				b := SearchLogsParams{}
+				err := json.Unmarshal(body, &b)
+				return b, err
+			},
+			HandlerFunc: searchLogsReqHF,
+		},
+		{
+			Method: ResizeMethod,
+			DecoderFunc: func(body []byte) (interface{}, error) {
+				b := ResizeParams{}
+				err := json.Unmarshal(body, &b)
+				return b, err
+			},
+			HandlerFunc: resizeReqHF,
+		},
+		{
+			Method: StartChainMethod,
+			DecoderFunc: func(body []byte) (interface{}, error) {
+				b := StartChainReqParams{}
+				err := json.Unmarshal(body, &b)
+				return b, err
+			},
+			HandlerFunc: startChainReqHF,
+		},
+		{
+			Method: GetChainMethod,
+			DecoderFunc: func(body []byte) (interface{}, error) {
+				b := GetChainParams{}
+				err := json.Unmarshal(body, &b)
+				return b, err
+			},
+			HandlerFunc: getChainReqHF,
+		},
 	},
 }
 
@@ -126,10 +229,32 @@ type ProcessResult struct {
 
 // StartParams represents params for start process call
 type StartParams struct {
-	Name        string `json:"name"`
-	CommandLine string `json:"commandLine"`
-	Type        string `json:"type"`
-	EventTypes  string `json:"eventTypes"`
+	Name        string            `json:"name"`
+	CommandLine string            `json:"commandLine"`
+	Type        string            `json:"type"`
+	Interpreter string            `json:"interpreter"`
+	Argv        []string          `json:"argv"`
+	EventTypes  string            `json:"eventTypes"`
+	Env         map[string]string `json:"env"`
+	CleanEnv    bool              `json:"cleanEnv"`
+	WorkingDir  string            `json:"workingDir"`
+	Stdin       bool              `json:"stdin"`
+	Timeout     int               `json:"timeout"`
+
+	RestartPolicy  string `json:"restartPolicy"`
+	MaxRetries     int    `json:"maxRetries"`
+	RestartBackoff int    `json:"restartBackoff"`
+
+	Readiness *process.ReadinessProbe `json:"readiness"`
+	Limits    *process.Limits         `json:"limits"`
+	Output    *process.OutputOptions  `json:"output"`
+	Tty       bool                    `json:"tty"`
+	Cols      int                     `json:"cols"`
+	Rows      int                     `json:"rows"`
+	StripAnsi bool                    `json:"stripAnsi"`
+	User      string                  `json:"user"`
+
+	Labels map[string]string `json:"labels"`
 }
 
 func startProcessReqHF(params interface{}, t *rpc.Transmitter) error {
@@ -138,6 +263,28 @@ func startProcessReqHF(params interface{}, t *rpc.Transmitter) error {
 		Name:        startParams.Name,
 		CommandLine: startParams.CommandLine,
 		Type:        startParams.Type,
+		Interpreter: startParams.Interpreter,
+		Argv:        startParams.Argv,
+		Env:         startParams.Env,
+		CleanEnv:    startParams.CleanEnv,
+		WorkingDir:  startParams.WorkingDir,
+		Stdin:       startParams.Stdin,
+		Timeout:     startParams.Timeout,
+
+		RestartPolicy:  startParams.RestartPolicy,
+		MaxRetries:     startParams.MaxRetries,
+		RestartBackoff: startParams.RestartBackoff,
+
+		Readiness: startParams.Readiness,
+		Limits:    startParams.Limits,
+		Output:    startParams.Output,
+		Tty:       startParams.Tty,
+		Cols:      startParams.Cols,
+		Rows:      startParams.Rows,
+		StripAnsi: startParams.StripAnsi,
+		User:      startParams.User,
+
+		Labels: startParams.Labels,
 	}
 	if err := checkCommand(&command); err != nil {
 		return rpc.NewArgsError(err)
@@ -155,13 +302,15 @@ func startProcessReqHF(params interface{}, t *rpc.Transmitter) error {
 
 // KillParams represents params for kill process call
 type KillParams struct {
-	Pid       uint64 `json:"pid"`
-	NativePid uint64 `json:"nativePid"`
+	Pid         uint64 `json:"pid"`
+	NativePid   uint64 `json:"nativePid"`
+	Graceful    bool   `json:"graceful"`
+	GracePeriod int    `json:"gracePeriod"`
 }
 
 func killProcessReqHF(params interface{}, t *rpc.Transmitter) error {
 	killParams := params.(KillParams)
-	if err := process.Kill(killParams.Pid); err != nil {
+	if err := kill(killParams.Pid, killParams.Graceful, killParams.GracePeriod); err != nil {
 		return asRPCError(err)
 	}
 	t.Send(&ProcessResult{
@@ -334,12 +483,188 @@ func getProcessReqHF(body interface{}, t *rpc.Transmitter) error {
 
 // GetProcessesParams represents params for get processes call
 type GetProcessesParams struct {
-	All bool `json:"all"`
+	All           bool   `json:"all"`
+	Alive         *bool  `json:"alive"`
+	Labels        string `json:"labels"`
+	Name          string `json:"name"`
+	Type          string `json:"type"`
+	StartedAfter  string `json:"startedAfter"`
+	StartedBefore string `json:"startedBefore"`
+	Sort          string `json:"sort"`
+	Order         string `json:"order"`
+	Skip          int    `json:"skip"`
+	Limit         int    `json:"limit"`
 }
 
 func getProcessesReqHF(body interface{}, t *rpc.Transmitter) error {
 	params := body.(GetProcessesParams)
-	t.Send(process.GetProcesses(params.All))
+	filter, err := newFilter(params)
+	if err != nil {
+		return rpc.NewArgsError(err)
+	}
+	t.Send(process.GetProcesses(filter))
+	return nil
+}
+
+// InputParams represents params for writing to the process input call
+type InputParams struct {
+	Pid   uint64 `json:"pid"`
+	Text  string `json:"text"`
+	Data  string `json:"data"`
+	Close bool   `json:"close"`
+}
+
+func inputReqHF(body interface{}, t *rpc.Transmitter) error {
+	params := body.(InputParams)
+	input, err := inputBytes(params.Text, params.Data)
+	if err != nil {
+		return rpc.NewArgsError(err)
+	}
+	if err := writeInput(params.Pid, input, params.Close); err != nil {
+		return asRPCError(err)
+	}
+	t.Send(&ProcessResult{
+		Pid:  params.Pid,
+		Text: "Successfully written",
+	})
+	return nil
+}
+
+// SignalParams represents params for send signal to process call
+type SignalParams struct {
+	Pid       uint64 `json:"pid"`
+	Signal    string `json:"signal"`
+	NativePid int    `json:"nativePid"`
+}
+
+func signalReqHF(body interface{}, t *rpc.Transmitter) error {
+	params := body.(SignalParams)
+	sig, err := process.ParseSignal(params.Signal)
+	if err != nil {
+		return rpc.NewArgsError(err)
+	}
+	if params.NativePid < 0 {
+		return rpc.NewArgsError(errors.New("NativePid must be a positive integer"))
+	}
+	if err := signal(params.Pid, params.NativePid, sig); err != nil {
+		return asRPCError(err)
+	}
+	t.Send(&ProcessResult{
+		Pid:  params.Pid,
+		Text: "Successfully signaled",
+	})
+	return nil
+}
+
+// GetStatsParams represents params for get process stats call
+type GetStatsParams struct {
+	Pid uint64 `json:"pid"`
+}
+
+func getStatsReqHF(body interface{}, t *rpc.Transmitter) error {
+	params := body.(GetStatsParams)
+	stats, err := process.GetStats(params.Pid)
+	if err != nil {
+		return asRPCError(err)
+	}
+	t.Send(stats)
+	return nil
+}
+
+// GetTreeParams represents params for get process tree call
+type GetTreeParams struct {
+	Pid uint64 `json:"pid"`
+}
+
+func getTreeReqHF(body interface{}, t *rpc.Transmitter) error {
+	params := body.(GetTreeParams)
+	tree, err := process.GetTree(params.Pid)
+	if err != nil {
+		return asRPCError(err)
+	}
+	t.Send(tree)
+	return nil
+}
+
+// WaitParams represents params for wait process call
+type WaitParams struct {
+	Pid     uint64 `json:"pid"`
+	Timeout string `json:"timeout"`
+}
+
+func waitReqHF(body interface{}, t *rpc.Transmitter) error {
+	params := body.(WaitParams)
+	timeout, err := parseWaitTimeout(params.Timeout)
+	if err != nil {
+		return rpc.NewArgsError(err)
+	}
+	if _, err := process.Get(params.Pid); err != nil {
+		return asRPCError(err)
+	}
+
+	// messages of the channel are handled one by one, so wait in background
+	// the result is not sent if the channel is closed while waiting
+	go func() {
+		proc, err := process.WaitOrCancel(params.Pid, timeout, t.Channel.Closed())
+		if err != nil {
+			t.SendError(rpc.NewError(err, rpc.InternalErrorCode))
+			return
+		}
+		t.Send(proc)
+	}()
+	return nil
+}
+
+// ForgetParams represents params for forget process call
+type ForgetParams struct {
+	Pid uint64 `json:"pid"`
+}
+
+func forgetReqHF(body interface{}, t *rpc.Transmitter) error {
+	params := body.(ForgetParams)
+	if err := process.Forget(params.Pid); err != nil {
+		return asRPCError(err)
+	}
+	t.Send(&ProcessResult{
+		Pid:  params.Pid,
+		Text: "Successfully forgotten",
+	})
+	return nil
+}
+
+// SearchLogsParams represents params for search logs call
+type SearchLogsParams struct {
+	Query   string   `json:"query"`
+	Pids    []uint64 `json:"pids"`
+	Kind    string   `json:"kind"`
+	From    string   `json:"from"`
+	Till    string   `json:"till"`
+	Context int      `json:"context"`
+	Skip    int      `json:"skip"`
+	Limit   int      `json:"limit"`
+}
+
+func searchLogsReqHF(body interface{}, t *rpc.Transmitter) error {
+	params := body.(SearchLogsParams)
+	query, err := newSearchQuery(params)
+	if err != nil {
+		return rpc.NewArgsError(err)
+	}
+
+	// messages of the channel are handled one by one, so search in background
+	// the result is not sent if the channel is closed while searching
+	go func() {
+		result, err := process.SearchLogs(query)
+		if err != nil {
+			if rpcErr, ok := asRPCError(err).(rpc.Error); ok {
+				t.SendError(rpcErr)
+			} else {
+				t.SendError(rpc.NewError(err, rpc.InternalErrorCode))
+			}
+			return
+		}
+		t.Send(result)
+	}()
 	return nil
 }
 
@@ -348,6 +673,84 @@ func asRPCError(err error) error {
 		return rpc.NewError(npErr, NoSuchProcessErrorCode)
 	} else if naErr, ok := err.(*process.NotAliveError); ok {
 		return rpc.NewError(naErr, ProcessNotAliveErrorCode)
+	} else if aErr, ok := err.(*process.AliveError); ok {
+		return rpc.NewError(aErr, ProcessAliveErrorCode)
+	} else if nsErr, ok := err.(*process.NotInSessionError); ok {
+		return rpc.NewArgsError(nsErr)
+	} else if ncErr, ok := err.(*process.NoChainError); ok {
+		return rpc.NewError(ncErr, NoSuchChainErrorCode)
 	}
 	return err
 }
+
+// ResizeParams represents params for resize process terminal call
+type ResizeParams struct {
+	Pid  uint64 `json:"pid"`
+	Cols int    `json:"cols"`
+	Rows int    `json:"rows"`
+}
+
+func resizeReqHF(body interface{}, t *rpc.Transmitter) error {
+	params := body.(ResizeParams)
+	if params.Cols <= 0 || params.Rows <= 0 {
+		return rpc.NewArgsError(errors.New("Terminal cols and rows must be > 0"))
+	}
+	if err := process.CheckTtySize(params.Cols, params.Rows); err != nil {
+		return rpc.NewArgsError(err)
+	}
+	p, err := process.Get(params.Pid)
+	if err != nil {
+		return asRPCError(err)
+	}
+	if !p.Tty {
+		return rpc.NewArgsError(fmt.Errorf("Process '%d' doesn't have a terminal", params.Pid))
+	}
+	if err := process.Resize(params.Pid, params.Cols, params.Rows); err != nil {
+		return asRPCError(err)
+	}
+	t.Send(&ProcessResult{
+		Pid:  params.Pid,
+		Text: "Successfully resized",
+	})
+	return nil
+}
+
+// StartChainReqParams represents params for start chain call
+type StartChainReqParams struct {
+	Steps      []process.ChainStep `json:"steps"`
+	EventTypes string              `json:"eventTypes"`
+}
+
+func startChainReqHF(body interface{}, t *rpc.Transmitter) error {
+	params := body.(StartChainReqParams)
+	if err := checkChain(params.Steps); err != nil {
+		return rpc.NewArgsError(err)
+	}
+	sub := process.Subscriber{
+		ID:       t.Channel.ID,
+		Mask:     parseTypes(params.EventTypes),
+		Consumer: &rpcProcessEventConsumer{t.Channel.Events},
+	}
+	// the steps are already validated, so the error is not caused by the arguments
+	chain, err := process.StartChain(params.Steps, sub)
+	if err != nil {
+		return err
+	}
+	t.Send(chain)
+	return nil
+}
+
+// GetChainParams represents params for get chain call
+type GetChainParams struct {
+	ID uint64 `json:"id"`
+}
+
+func getChainReqHF(body interface{}, t *rpc.Transmitter) error {
+	params := body.(GetChainParams)
+	chain, err := process.GetChain(params.ID)
+	if err != nil {
+		return asRPCError(err)
+	}
+	t.Send(chain)
+	return nil
+}
@@ -17,6 +17,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/eclipse/che/agents/go-agents/core/auth"
@@ -35,6 +36,9 @@ func init() {
 }
 
 func main() {
+	// exec-agent binary is started as the limits helper of the limited processes
+	process.RunLimitsHelper()
+
 	flag.Parse()
 
 	log.SetOutput(os.Stdout)
@@ -42,19 +46,48 @@ func main() {
 	config.printAll()
 
 	process.SetLogsDir(config.processLogsDir)
+	if config.processLogsDir == "" {
+		process.SetLogStore(process.NewMemoryLogStore(config.logsMemoryCapacity))
+	}
 	process.SetShellInterpreter(config.processShellInterpreter)
-
-	// remove old logs
-	if err := process.WipeLogs(); err != nil {
+	if err := process.SetAllowedInterpreters(strings.Split(config.processInterpreters, ",")); err != nil {
 		log.Fatal(err)
 	}
+	process.SetDefaultTimeout(config.processDefaultTimeoutInSeconds)
+	process.SetAllowedUsers(strings.Split(config.processAllowedUsers, ","))
+	process.SetAllowedGroups(strings.Split(config.processAllowedGroups, ","))
+	process.SetLimitsCgroup(config.processLimitsCgroup)
+	process.SetLogsRotation(
+		int64(config.logsMaxSizeInMegabytes)*1024*1024,
+		time.Minute*time.Duration(config.logsMaxAgeInMinutes),
+		config.logsMaxSegments,
+	)
+
+	if config.processRegistryEnabled {
+		// restore processes persisted before the restart
+		if err := process.EnableRegistry(); err != nil {
+			log.Fatal(err)
+		}
+		if err := process.RestoreProcesses(); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		// remove old logs
+		if err := process.WipeLogs(); err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	// start cleaner routine
 	if config.processCleanupPeriodInMinutes > 0 {
-		if config.processCleanupThresholdInMinutes < 0 {
-			log.Fatal("Expected process cleanup threshold to be non negative value")
+		if config.processCleanupThresholdInMinutes < 0 &&
+			config.processCleanupMaxDead <= 0 &&
+			config.processCleanupMaxLogsInMegabytes <= 0 {
+			log.Fatal("Expected process cleanup threshold to be non negative value or cleanup max dead processes or max logs size to be configured")
 		}
 		cleaner := process.NewCleaner(config.processCleanupPeriodInMinutes, config.processCleanupThresholdInMinutes)
+		cleaner.MaxDeadProcesses = config.processCleanupMaxDead
+		cleaner.MaxLogsSize = int64(config.processCleanupMaxLogsInMegabytes) * 1024 * 1024
 		go cleaner.CleanPeriodically()
 	}
 
@@ -79,8 +112,8 @@ func main() {
 	server := &http.Server{
 		Handler:      handler,
 		Addr:         config.serverAddress,
-		WriteTimeout: 10 * time.Second,
-		ReadTimeout:  10 * time.Second,
+		WriteTimeout: exec.ServerWriteTimeout,
+		ReadTimeout:  exec.ServerReadTimeout,
 	}
 	log.Fatal(server.ListenAndServe())
 }
@@ -117,9 +150,21 @@ type execAgentConfig struct {
 	tokensExpirationTimeoutInMinutes uint
 
 	processShellInterpreter          string
+	processInterpreters              string
 	processLogsDir                   string
 	processCleanupThresholdInMinutes int
 	processCleanupPeriodInMinutes    int
+	processCleanupMaxDead            int
+	processCleanupMaxLogsInMegabytes int
+	processDefaultTimeoutInSeconds   int
+	processRegistryEnabled           bool
+	processAllowedUsers              string
+	processAllowedGroups             string
+	processLimitsCgroup              string
+	logsMaxSizeInMegabytes           int
+	logsMaxAgeInMinutes              int
+	logsMaxSegments                  int
+	logsMemoryCapacity               int
 }
 
 func (cfg *execAgentConfig) registerFlags() {
@@ -172,6 +217,14 @@ func (cfg *execAgentConfig) registerFlags() {
 		process.DefaultShellInterpreter,
 		"shell interpreter",
 	)
+	flag.StringVar(
+		&cfg.processInterpreters,
+		"process-interpreters",
+		"",
+		`comma separated interpreters commands may specify, either the name of the known interpreter
+	(bash, sh, zsh, python, node) or the name followed by '=' and the program with its options
+	e.g. 'python3=/usr/bin/python3 -c'. If empty then commands are executed only by 'cmd' interpreter`,
+	)
 	flag.IntVar(
 		&cfg.processCleanupPeriodInMinutes,
 		"process-cleanup-period",
@@ -185,6 +238,58 @@ func (cfg *execAgentConfig) registerFlags() {
 	if -1 passed then processes won't be cleaned at all. Please note that the time
 	of real cleanup is between configured threshold and threshold + process-cleanup-period.`,
 	)
+	flag.IntVar(
+		&cfg.processCleanupMaxDead,
+		"process-cleanup-max-dead",
+		0,
+		`how many dead processes may stay, the earliest died processes are cleaned up first.
+	If 0 passed then the number of dead processes is not limited`,
+	)
+	flag.IntVar(
+		&cfg.processCleanupMaxLogsInMegabytes,
+		"process-cleanup-max-logs-size",
+		0,
+		`the max size of all the process logs(in megabytes), the earliest died processes
+	are cleaned up first until logs fit the size. If 0 passed then the size is not limited.
+	Processes labeled with 'keep' are never cleaned up`,
+	)
+	flag.IntVar(
+		&cfg.processDefaultTimeoutInSeconds,
+		"process-default-timeout",
+		0,
+		`how much time process started without its own timeout may run(in seconds),
+	after this time the process is terminated. If 0 passed then such processes run until they exit`,
+	)
+	flag.BoolVar(
+		&cfg.processRegistryEnabled,
+		"process-registry",
+		false,
+		`whether to persist processes into the registry file in logs dir, so they
+	are restored after exec-agent restart. If enabled logs are not wiped on start.
+	The registry file is readable only by the agent's user, processes env is not persisted`,
+	)
+	flag.StringVar(
+		&cfg.processAllowedUsers,
+		"process-allowed-users",
+		"",
+		`comma separated names or uids of the users processes may run as,
+	'*' allows any user. If empty then processes run only as exec-agent user`,
+	)
+	flag.StringVar(
+		&cfg.processAllowedGroups,
+		"process-allowed-groups",
+		"",
+		`comma separated names or gids of the groups processes may run as in addition
+	to the groups of their users, '*' allows any group. If empty then only the groups of the users are allowed`,
+	)
+	flag.StringVar(
+		&cfg.processLimitsCgroup,
+		"process-limits-cgroup",
+		"",
+		`cgroup v2 directory the cgroups of the processes with limits are created under,
+	the cgroup must be either the root or have no processes of its own to delegate memory,
+	cpu and pids controllers. If empty then the cgroup of exec-agent is used`,
+	)
 	curDir, err := os.Getwd()
 	if err != nil {
 		log.Fatal(err)
@@ -194,7 +299,35 @@ func (cfg *execAgentConfig) registerFlags() {
 		&cfg.processLogsDir,
 		"logs-dir",
 		curDir,
-		"base directory for process logs",
+		"base directory for process logs, if empty then logs are kept in memory",
+	)
+	flag.IntVar(
+		&cfg.logsMemoryCapacity,
+		"logs-memory-capacity",
+		process.DefaultMemoryLogsCapacity,
+		`how many latest log lines are kept in memory per process
+	when logs-dir is empty, the earliest lines are dropped first`,
+	)
+	flag.IntVar(
+		&cfg.logsMaxSizeInMegabytes,
+		"logs-max-size",
+		0,
+		`the size of process logs file(in megabytes) after which it is rotated,
+	rotated segments are compressed. If 0 passed then logs are not rotated by size`,
+	)
+	flag.IntVar(
+		&cfg.logsMaxAgeInMinutes,
+		"logs-max-age",
+		0,
+		`the age of process logs file(in minutes) after which it is rotated,
+	rotated segments are compressed. If 0 passed then logs are not rotated by age`,
+	)
+	flag.IntVar(
+		&cfg.logsMaxSegments,
+		"logs-max-segments",
+		0,
+		`how many rotated segments of process logs are kept per process,
+	the oldest segments are removed first. If 0 passed then all the segments are kept`,
 	)
 }
 
@@ -211,10 +344,45 @@ func (cfg *execAgentConfig) printAll() {
 		log.Printf("    - API endpoint: %s\n", cfg.apiEndpoint)
 	}
 	log.Println("  Process executor")
-	log.Printf("    - Logs dir: %s\n", cfg.processLogsDir)
+	if cfg.processLogsDir != "" {
+		log.Printf("    - Logs dir: %s\n", cfg.processLogsDir)
+	} else {
+		log.Printf("    - Logs memory capacity: %d lines\n", cfg.logsMemoryCapacity)
+	}
+	if cfg.logsMaxSizeInMegabytes > 0 {
+		log.Printf("    - Logs max size: %dMB\n", cfg.logsMaxSizeInMegabytes)
+	}
+	if cfg.logsMaxAgeInMinutes > 0 {
+		log.Printf("    - Logs max age: %dm\n", cfg.logsMaxAgeInMinutes)
+	}
+	if cfg.logsMaxSegments > 0 {
+		log.Printf("    - Logs max segments: %d\n", cfg.logsMaxSegments)
+	}
+	log.Printf("    - Process registry enabled: %t\n", cfg.processRegistryEnabled)
+	if cfg.processAllowedUsers != "" {
+		log.Printf("    - Allowed users: %s\n", cfg.processAllowedUsers)
+	}
+	if cfg.processAllowedGroups != "" {
+		log.Printf("    - Allowed groups: %s\n", cfg.processAllowedGroups)
+	}
+	if cfg.processLimitsCgroup != "" {
+		log.Printf("    - Limits cgroup: %s\n", cfg.processLimitsCgroup)
+	}
+	if cfg.processInterpreters != "" {
+		log.Printf("    - Allowed interpreters: %s\n", cfg.processInterpreters)
+	}
+	if cfg.processDefaultTimeoutInSeconds > 0 {
+		log.Printf("    - Default process timeout: %ds\n", cfg.processDefaultTimeoutInSeconds)
+	}
 	if cfg.processCleanupPeriodInMinutes > 0 {
 		log.Printf("    - Cleanup job period: %dm\n", cfg.processCleanupPeriodInMinutes)
 		log.Printf("    - Not used & dead processes stay for: %dm\n", cfg.processCleanupThresholdInMinutes)
+		if cfg.processCleanupMaxDead > 0 {
+			log.Printf("    - Max dead processes: %d\n", cfg.processCleanupMaxDead)
+		}
+		if cfg.processCleanupMaxLogsInMegabytes > 0 {
+			log.Printf("    - Max logs size: %dMB\n", cfg.processCleanupMaxLogsInMegabytes)
+		}
 	}
 	log.Println()
 }